@@ -0,0 +1,74 @@
+package event
+
+import (
+	"context"
+	"math"
+)
+
+// FromEnd can be used as from when reading backwards to start at the last
+// record without knowing the version of the stream.
+const FromEnd uint64 = math.MaxUint64
+
+// BackwardStore is a Store that can read streams from the most recent record
+// towards the first one. Backward slices start at from (inclusive) and contain
+// the records in descending order. Their Next is the index of the record that
+// precedes the last record of the slice, and IsEndOfStream is set once the
+// first record of the stream has been read.
+type BackwardStore interface {
+	Store
+	LoadBackward(streamID string) RecordStream
+	LoadBackwardContext(ctx context.Context, streamID string) RecordStream
+	LoadSliceBackward(streamID string, from uint64, limit uint64) (*Slice, error)
+	LoadSliceBackwardContext(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error)
+}
+
+// loadBackward streams all records of a stream starting at the last record by
+// loading backward slices of batchSize.
+func loadBackward(ctx context.Context, loadSliceBackward loadSliceFunc, streamID string, batchSize uint64) RecordStream {
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		from := FromEnd
+		for {
+			slice, err := loadSliceBackward(ctx, streamID, from, batchSize)
+			if err != nil {
+				return
+			}
+			for _, e := range slice.Records {
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if slice.IsEndOfStream || len(slice.Records) == 0 {
+				return
+			}
+			from = slice.Next
+		}
+	}()
+	return out
+}
+
+// backwardSlice completes a backward slice of records that have been loaded
+// with a limit of limit+1 and stop at first.
+func backwardSlice(streamID string, from uint64, limit uint64, records Records, first uint64) *Slice {
+	slice := &Slice{
+		StreamID: streamID,
+		From:     from,
+		Records:  records,
+	}
+	slice.IsEndOfStream = uint64(len(records)) <= limit
+	if !slice.IsEndOfStream {
+		slice.Records = records[:limit]
+	}
+	if n := len(slice.Records); n > 0 {
+		last := slice.Records[n-1].StreamIndex
+		if last <= first {
+			slice.IsEndOfStream = true
+		} else {
+			slice.Next = last - 1
+		}
+	}
+	return slice
+}
@@ -0,0 +1,73 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStoreBackward(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(BackwardStore)
+		if !ok {
+			t.Skip("slices can not be loaded backward")
+		}
+		exersizeBackward(t, x)
+	})
+}
+
+func exersizeBackward(t *testing.T, s BackwardStore) {
+	for _, id := range []string{"foo", "bar", "foo", "foo", "bar", "foo"} {
+		if err := s.Append(id, ExpectAny, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	indexes := func(rs Records) []uint64 {
+		var got []uint64
+		for _, r := range rs {
+			got = append(got, r.StreamIndex)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name     string
+		streamID string
+		from     uint64
+		limit    uint64
+		exp      []uint64
+		next     uint64
+		end      bool
+	}{
+		{name: "stream from end", streamID: "foo", from: FromEnd, limit: 3, exp: []uint64{3, 2, 1}, next: 0},
+		{name: "stream to start", streamID: "foo", from: 1, limit: 3, exp: []uint64{1, 0}, end: true},
+		{name: "stream exact", streamID: "foo", from: 2, limit: 3, exp: []uint64{2, 1, 0}, end: true},
+		{name: "all from end", streamID: All, from: FromEnd, limit: 4, exp: []uint64{5, 4, 3, 2}, next: 1},
+		{name: "all from", streamID: All, from: 2, limit: 4, exp: []uint64{2, 1, 0}, end: true},
+		{name: "empty", streamID: "baz", from: FromEnd, limit: 4, end: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slice, err := s.LoadSliceBackward(test.streamID, test.from, test.limit)
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if got := indexes(slice.Records); !reflect.DeepEqual(test.exp, got) {
+				t.Errorf("want: %v, got: %v", test.exp, got)
+			}
+			if slice.IsEndOfStream != test.end {
+				t.Errorf("want: %t, got: %t", test.end, slice.IsEndOfStream)
+			}
+			if !test.end && slice.Next != test.next {
+				t.Errorf("want: %d, got: %d", test.next, slice.Next)
+			}
+		})
+	}
+
+	if got := indexes(s.LoadBackward("foo").Records()); !reflect.DeepEqual([]uint64{3, 2, 1, 0}, got) {
+		t.Errorf("want: %v, got: %v", []uint64{3, 2, 1, 0}, got)
+	}
+	if got := indexes(s.LoadBackward(All).Records()); !reflect.DeepEqual([]uint64{5, 4, 3, 2, 1, 0}, got) {
+		t.Errorf("want: %v, got: %v", []uint64{5, 4, 3, 2, 1, 0}, got)
+	}
+}
@@ -1,17 +1,20 @@
 package event
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/cognicraft/pubsub"
-	"github.com/cognicraft/sqlutil"
 )
 
 const (
@@ -27,7 +30,17 @@ const (
 )
 
 var (
-	_ Store = (*BasicStore)(nil)
+	_ Store               = (*BasicStore)(nil)
+	_ ContextStore        = (*BasicStore)(nil)
+	_ AppendResultStore   = (*BasicStore)(nil)
+	_ TransactionalStore  = (*BasicStore)(nil)
+	_ DeletableStore      = (*BasicStore)(nil)
+	_ StreamMetadataStore = (*BasicStore)(nil)
+	_ ListableStore       = (*BasicStore)(nil)
+	_ BackwardStore       = (*BasicStore)(nil)
+	_ TimeIndexedStore    = (*BasicStore)(nil)
+	_ QueryableStore      = (*BasicStore)(nil)
+	_ SnapshotStore       = (*BasicStore)(nil)
 )
 
 func NewBasicStore(dataSourceName string) (*BasicStore, error) {
@@ -48,50 +61,74 @@ type BasicStore struct {
 }
 
 func (s *BasicStore) Version(streamID string) uint64 {
-	var row *sql.Row
+	version, _ := s.VersionContext(context.Background(), streamID)
+	return version
+}
+
+func (s *BasicStore) VersionContext(ctx context.Context, streamID string) (uint64, error) {
 	if All == streamID {
-		row = s.db.QueryRow(`SELECT (storeIndex+1) as version FROM events ORDER BY storeIndex DESC LIMIT 1;`)
-	} else {
-		row = s.db.QueryRow(`SELECT (streamIndex+1) as version FROM events WHERE streamID = ? ORDER BY storeIndex DESC LIMIT 1;`, streamID, 0)
+		return queryStoreVersion(ctx, s.db)
 	}
-	var version uint64
-	err := row.Scan(&version)
+	if category, ok := isCategoryStream(streamID); ok {
+		lower, upper := categoryRange(category)
+		var version sql.NullInt64
+		row := s.db.QueryRowContext(ctx, `SELECT MAX(storeIndex)+1 FROM events WHERE streamID >= ? AND streamID < ?;`, lower, upper)
+		err := row.Scan(&version)
+		return uint64(version.Int64), err
+	}
+	if typ, ok := isEventTypeStream(streamID); ok {
+		var version sql.NullInt64
+		row := s.db.QueryRowContext(ctx, `SELECT MAX(storeIndex)+1 FROM events WHERE type = ?;`, typ)
+		err := row.Scan(&version)
+		return uint64(version.Int64), err
+	}
+	version, err := queryStreamVersion(ctx, s.db, streamID)
 	if err != nil {
-		// stream does not exist
-		return 0
+		return 0, err
 	}
-	return version
+	deleted, err := queryDeletedVersion(ctx, s.db, streamID)
+	if err != nil {
+		return 0, err
+	}
+	return visibleVersion(version, deleted), nil
 }
 
 func (s *BasicStore) Load(streamID string) RecordStream {
 	return s.LoadFrom(streamID, 0)
 }
 
+func (s *BasicStore) LoadContext(ctx context.Context, streamID string) RecordStream {
+	return s.LoadFromContext(ctx, streamID, 0)
+}
+
 func (s *BasicStore) LoadFrom(streamID string, skip uint64) RecordStream {
-	out := make(chan Record)
-	go func() {
-		defer close(out)
-		next := skip
-		for {
-			slice, err := s.LoadSlice(streamID, next, s.batchSize)
-			if err != nil {
-				return
-			}
-			for _, e := range slice.Records {
-				out <- e
-			}
-			if slice.IsEndOfStream {
-				return
-			}
-			next = slice.Next
-		}
-	}()
-	return out
+	return s.LoadFromContext(context.Background(), streamID, skip)
+}
+
+func (s *BasicStore) LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream {
+	return loadFrom(ctx, s.LoadSliceContext, streamID, skip, s.batchSize)
 }
 
 func (s *BasicStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceContext(context.Background(), streamID, skip, limit)
+}
+
+func (s *BasicStore) LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSlice(ctx, streamID, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *BasicStore) loadSlice(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	first, err := s.first(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	skip = max(skip, first)
+
 	var rows *sql.Rows
-	var err error
 	if All == streamID {
 		query := `
 		SELECT '$all', storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
@@ -99,7 +136,27 @@ func (s *BasicStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Sli
 		WHERE  storeIndex >= ?
 		ORDER  BY storeIndex
 		LIMIT  ?;`
-		rows, err = s.db.Query(query, int64(skip), int64(limit)+1)
+		rows, err = s.db.QueryContext(ctx, query, int64(skip), int64(limit)+1)
+	} else if category, ok := isCategoryStream(streamID); ok {
+		lower, upper := categoryRange(category)
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID >= ?
+		       AND streamID < ?
+		       AND storeIndex >= ?
+		ORDER  BY storeIndex
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, streamID, lower, upper, int64(skip), int64(limit)+1)
+	} else if typ, ok := isEventTypeStream(streamID); ok {
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  type = ?
+		       AND storeIndex >= ?
+		ORDER  BY storeIndex
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, streamID, typ, int64(skip), int64(limit)+1)
 	} else {
 		query := `
 		SELECT streamID, streamIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
@@ -108,7 +165,7 @@ func (s *BasicStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Sli
 		       AND streamIndex >= ?
 		ORDER  BY streamIndex
 		LIMIT  ?;`
-		rows, err = s.db.Query(query, streamID, int64(skip), int64(limit)+1)
+		rows, err = s.db.QueryContext(ctx, query, streamID, int64(skip), int64(limit)+1)
 	}
 	if err != nil {
 		return nil, err
@@ -135,95 +192,579 @@ func (s *BasicStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Sli
 	return &slice, nil
 }
 
+func (s *BasicStore) LoadBackward(streamID string) RecordStream {
+	return s.LoadBackwardContext(context.Background(), streamID)
+}
+
+func (s *BasicStore) LoadBackwardContext(ctx context.Context, streamID string) RecordStream {
+	return loadBackward(ctx, s.LoadSliceBackwardContext, streamID, s.batchSize)
+}
+
+func (s *BasicStore) LoadSliceBackward(streamID string, from uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceBackwardContext(context.Background(), streamID, from, limit)
+}
+
+func (s *BasicStore) LoadSliceBackwardContext(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSliceBackward(ctx, streamID, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *BasicStore) loadSliceBackward(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	first, err := s.first(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	if All == streamID {
+		query := `
+		SELECT '$all', storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, int64(min(from, math.MaxInt64)), int64(limit)+1)
+	} else if category, ok := isCategoryStream(streamID); ok {
+		lower, upper := categoryRange(category)
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID >= ?
+		       AND streamID < ?
+		       AND storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, streamID, lower, upper, int64(min(from, math.MaxInt64)), int64(limit)+1)
+	} else if typ, ok := isEventTypeStream(streamID); ok {
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  type = ?
+		       AND storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, streamID, typ, int64(min(from, math.MaxInt64)), int64(limit)+1)
+	} else {
+		query := `
+		SELECT streamID, streamIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID = ?
+		       AND streamIndex <= ?
+		       AND streamIndex >= ?
+		ORDER  BY streamIndex DESC
+		LIMIT  ?;`
+		rows, err = s.db.QueryContext(ctx, query, streamID, int64(min(from, math.MaxInt64)), int64(first), int64(limit)+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs, err := records(rows)
+	if err != nil {
+		return nil, err
+	}
+	return backwardSlice(streamID, from, limit, recs, first), nil
+}
+
+// first returns the index of the first record of a stream that is retained by
+// its metadata and not hidden by a soft delete.
+func (s *BasicStore) first(ctx context.Context, streamID string) (uint64, error) {
+	if All == streamID {
+		return 0, nil
+	}
+	meta, err := s.StreamMetadataContext(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := queryDeletedVersion(ctx, s.db, streamID)
+	if err != nil || meta.IsZero() {
+		return deleted, err
+	}
+	version, err := s.VersionContext(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	first := max(meta.first(version), deleted)
+	if meta.MaxAge <= 0 || first >= version {
+		return first, nil
+	}
+	var index sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT MIN(streamIndex) FROM events WHERE streamID = ? AND streamIndex >= ? AND recordedOn >= ?;`,
+		streamID, int64(first), formatTime(meta.cutoff(time.Now().UTC())))
+	if err := row.Scan(&index); err != nil {
+		return 0, err
+	}
+	if !index.Valid {
+		return version, nil
+	}
+	return uint64(index.Int64), nil
+}
+
+func (s *BasicStore) Query(skip uint64, limit uint64, conditions ...Condition) (*Slice, error) {
+	return s.QueryContext(context.Background(), skip, limit, conditions...)
+}
+
+func (s *BasicStore) QueryContext(ctx context.Context, skip uint64, limit uint64, conditions ...Condition) (*Slice, error) {
+	query, args, err := queryStatement(skip, limit+1, conditions)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recs, err := records(rows)
+	if err != nil {
+		return nil, err
+	}
+	return querySlice(skip, limit, recs), nil
+}
+
+func (s *BasicStore) IndexPath(field Field, path string) error {
+	stmt, err := jsonIndex(field, path)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(stmt)
+	return err
+}
+
 func (s *BasicStore) Append(streamID string, expectedVersion uint64, records Records) error {
+	return s.AppendContext(context.Background(), streamID, expectedVersion, records)
+}
+
+func (s *BasicStore) AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	_, err := s.AppendWithResult(ctx, streamID, expectedVersion, records)
+	return err
+}
+
+func (s *BasicStore) AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
 	if All == streamID {
-		return s.appendToStore(expectedVersion, records)
+		return s.appendToStore(ctx, expectedVersion, records)
+	}
+	results, err := s.AppendMulti(ctx, StreamAppend{StreamID: streamID, ExpectedVersion: expectedVersion, Records: records})
+	if err != nil {
+		return AppendResult{}, err
+	}
+	return results[0], nil
+}
+
+func (s *BasicStore) AppendMulti(ctx context.Context, appends ...StreamAppend) ([]AppendResult, error) {
+	if err := checkStreamAppends(appends); err != nil {
+		return nil, err
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	err := sqlutil.Transact(s.db, func(tx *sql.Tx) error {
-		streamVersion := uint64(0)
-		row := tx.QueryRow(`SELECT (streamIndex+1) as version FROM events WHERE streamID = ? ORDER BY streamIndex DESC LIMIT 1;`, streamID)
-		row.Scan(&streamVersion)
-		if streamVersion != expectedVersion {
-			return OptimisticConcurrencyError{Stream: streamID, Expected: expectedVersion, Actual: streamVersion}
-		}
-
-		storeVersion := uint64(0)
-		row = tx.QueryRow(`SELECT (storeIndex+1) as version FROM events ORDER BY storeIndex DESC LIMIT 1;`)
-		row.Scan(&storeVersion)
-
-		for _, e := range records {
-			storeIndex := uint64(storeVersion)
-			storeVersion++
-			streamIndex := uint64(streamVersion)
-			streamVersion++
-			if e.RecordedOn.IsZero() {
-				e.RecordedOn = time.Now().UTC()
-			}
-			e.StreamID = streamID
-			e.StreamIndex = streamIndex
-			e.OriginStreamID = streamID
-			e.OriginStreamIndex = streamIndex
-			if _, err := tx.Exec(`INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
-				storeIndex, streamID, streamIndex, formatTime(e.RecordedOn), e.ID, e.Type, []byte(e.Data), []byte(e.Metadata)); err != nil {
+	results := make([]AppendResult, len(appends))
+	err := transact(ctx, s.db, func(tx *sql.Tx) error {
+		for i, a := range appends {
+			res, err := s.appendTx(ctx, tx, a.StreamID, a.ExpectedVersion, a.Records)
+			if err != nil {
 				return err
 			}
+			results[i] = res
 		}
 		return nil
 	})
-	if err == nil {
-		s.publisher.Publish(topicAppend, streamID)
+	if err != nil {
+		return nil, err
 	}
-	return err
+	for _, res := range results {
+		if res.Count() > 0 {
+			s.publisher.Publish(topicAppend, res.StreamID)
+		}
+	}
+	return results, nil
 }
 
-func (s *BasicStore) appendToStore(expectedVersion uint64, records Records) error {
+// appendTx appends records to a stream within tx. A retried append is a no-op
+// and results in an AppendResult without records.
+func (s *BasicStore) appendTx(ctx context.Context, tx *sql.Tx, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
+	if err := checkTombstone(ctx, tx, streamID); err != nil {
+		return AppendResult{}, err
+	}
+	res := AppendResult{StreamID: streamID}
+	streamVersion, err := queryStreamVersion(ctx, tx, streamID)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	res.Version = streamVersion
+	deleted, err := queryDeletedVersion(ctx, tx, streamID)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	if err := checkExpectedStreamVersion(streamID, expectedVersion, streamVersion, deleted); err != nil {
+		dup, dErr := checkIdempotency(streamID, expectedVersion, streamVersion, deleted, records, func(skip uint64, limit uint64) ([]string, error) {
+			return recordIDs(ctx, tx, streamID, skip, limit)
+		})
+		if dup {
+			return res, nil
+		}
+		if dErr != nil {
+			return AppendResult{}, dErr
+		}
+		return AppendResult{}, err
+	}
+
+	storeVersion, err := queryStoreVersion(ctx, tx)
+	if err != nil {
+		return AppendResult{}, err
+	}
+
+	for _, e := range records {
+		storeIndex := uint64(storeVersion)
+		storeVersion++
+		streamIndex := uint64(streamVersion)
+		streamVersion++
+		if e.RecordedOn.IsZero() {
+			e.RecordedOn = time.Now().UTC()
+		}
+		e.StreamID = streamID
+		e.StreamIndex = streamIndex
+		e.OriginStreamID = streamID
+		e.OriginStreamIndex = streamIndex
+		if _, err := tx.ExecContext(ctx, `INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			storeIndex, streamID, streamIndex, formatTime(e.RecordedOn), e.ID, e.Type, []byte(e.Data), []byte(e.Metadata)); err != nil {
+			return AppendResult{}, err
+		}
+		res.add(streamIndex, storeIndex, e.RecordedOn)
+	}
+	return res, nil
+}
+
+func (s *BasicStore) appendToStore(ctx context.Context, expectedVersion uint64, records Records) (AppendResult, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	res := AppendResult{StreamID: All}
 	updatedStreams := map[string]bool{}
 
-	err := sqlutil.Transact(s.db, func(tx *sql.Tx) error {
+	err := transact(ctx, s.db, func(tx *sql.Tx) error {
 
-		storeVersion := uint64(0)
-		row := tx.QueryRow(`SELECT (storeIndex+1) as version FROM events ORDER BY storeIndex DESC LIMIT 1;`)
-		row.Scan(&storeVersion)
+		storeVersion, err := queryStoreVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
 
-		if storeVersion != expectedVersion {
-			return OptimisticConcurrencyError{Stream: All, Expected: expectedVersion, Actual: storeVersion}
+		if err := checkExpectedVersion(All, expectedVersion, storeVersion); err != nil {
+			return err
+		}
+		for i, e := range records {
+			if e.StreamIndex != storeVersion+uint64(i) {
+				return fmt.Errorf("record %d has store index %d but expected %d", i, e.StreamIndex, storeVersion+uint64(i))
+			}
 		}
+		res.Version = storeVersion
 
 		for _, e := range records {
-			if _, err := tx.Exec(`INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			if _, err := tx.ExecContext(ctx, `INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
 				e.StreamIndex, e.OriginStreamID, e.OriginStreamIndex, formatTime(e.RecordedOn), e.ID, e.Type, []byte(e.Data), []byte(e.Metadata)); err != nil {
 				return err
 			}
 			updatedStreams[e.OriginStreamID] = true
+			res.add(e.StreamIndex, e.StreamIndex, e.RecordedOn)
 		}
 		return nil
 	})
-	if err == nil {
-		for streamID := range updatedStreams {
-			s.publisher.Publish(topicAppend, streamID)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	for streamID := range updatedStreams {
+		s.publisher.Publish(topicAppend, streamID)
+	}
+	return res, nil
+}
+
+func (s *BasicStore) DeleteStream(streamID string, expectedVersion uint64, hard bool) error {
+	return s.DeleteStreamContext(context.Background(), streamID, expectedVersion, hard)
+}
+
+func (s *BasicStore) DeleteStreamContext(ctx context.Context, streamID string, expectedVersion uint64, hard bool) error {
+	if All == streamID {
+		return fmt.Errorf("%s can not be deleted", All)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return transact(ctx, s.db, func(tx *sql.Tx) error {
+		if err := checkTombstone(ctx, tx, streamID); err != nil {
+			return err
+		}
+		streamVersion, err := queryStreamVersion(ctx, tx, streamID)
+		if err != nil {
+			return err
+		}
+		deleted, err := queryDeletedVersion(ctx, tx, streamID)
+		if err != nil {
+			return err
 		}
+		if err := checkExpectedStreamVersion(streamID, expectedVersion, streamVersion, deleted); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE streamID = ?;`, streamID); err != nil {
+			return err
+		}
+		if !hard {
+			_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO soft_deleted_streams (streamID, version) VALUES (?, ?);`, streamID, streamVersion)
+			return err
+		}
+		storeVersion, err := queryStoreVersion(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			`DELETE FROM events WHERE streamID = ?;`,
+			`DELETE FROM stream_metadata WHERE streamID = ?;`,
+			`DELETE FROM soft_deleted_streams WHERE streamID = ?;`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, streamID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO deleted_streams (streamID, storeVersion) VALUES (?, ?);`, streamID, storeVersion)
+		return err
+	})
+}
+
+func (s *BasicStore) LoadSnapshot(streamID string) (Snapshot, error) {
+	return s.LoadSnapshotContext(context.Background(), streamID)
+}
+
+func (s *BasicStore) LoadSnapshotContext(ctx context.Context, streamID string) (Snapshot, error) {
+	var snapshot Snapshot
+	var recordedOn string
+	var data []byte
+	row := s.db.QueryRowContext(ctx, `SELECT streamID, version, recordedOn, data FROM snapshots WHERE streamID = ? LIMIT 1;`, streamID)
+	err := row.Scan(&snapshot.StreamID, &snapshot.Version, &recordedOn, &data)
+	if err == sql.ErrNoRows {
+		return Snapshot{}, nil
+	}
+	if err != nil {
+		return Snapshot{}, err
 	}
+	snapshot.RecordedOn = parseTime(recordedOn)
+	snapshot.Data = data
+	return snapshot, nil
+}
+
+func (s *BasicStore) SaveSnapshot(snapshot Snapshot) error {
+	return s.SaveSnapshotContext(context.Background(), snapshot)
+}
+
+func (s *BasicStore) SaveSnapshotContext(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := s.VersionContext(ctx, snapshot.StreamID)
+	if err != nil {
+		return err
+	}
+	if err := checkSnapshot(snapshot, version); err != nil {
+		return err
+	}
+	if snapshot.RecordedOn.IsZero() {
+		snapshot.RecordedOn = time.Now().UTC()
+	}
+	_, err = s.db.ExecContext(ctx, `
+	INSERT INTO snapshots (streamID, version, recordedOn, data) VALUES (?, ?, ?, ?)
+	ON CONFLICT (streamID) DO UPDATE SET version = excluded.version, recordedOn = excluded.recordedOn, data = excluded.data
+	WHERE excluded.version >= snapshots.version;`,
+		snapshot.StreamID, snapshot.Version, formatTime(snapshot.RecordedOn), []byte(snapshot.Data))
 	return err
 }
 
-func (s *BasicStore) SubscribeToStream(streamID string) Subscription {
-	return &subscription{
-		loadSlice: s.LoadSlice,
-		batchSize: s.batchSize,
-		subscribe: s.publisher.Subscribe,
-		streamID:  streamID,
-		from:      0,
+func (s *BasicStore) StreamMetadata(streamID string) (StreamMetadata, error) {
+	return s.StreamMetadataContext(context.Background(), streamID)
+}
+
+func (s *BasicStore) StreamMetadataContext(ctx context.Context, streamID string) (StreamMetadata, error) {
+	return queryStreamMetadata(ctx, s.db, streamID)
+}
+
+func (s *BasicStore) SetStreamMetadata(streamID string, meta StreamMetadata) error {
+	return s.SetStreamMetadataContext(context.Background(), streamID, meta)
+}
+
+func (s *BasicStore) SetStreamMetadataContext(ctx context.Context, streamID string, meta StreamMetadata) error {
+	if All == streamID {
+		return fmt.Errorf("metadata can not be set for %s", All)
+	}
+	return setStreamMetadata(ctx, s.db, streamID, meta)
+}
+
+func (s *BasicStore) Streams(prefix string, after string, limit int) ([]StreamInfo, error) {
+	return s.StreamsContext(context.Background(), prefix, after, limit)
+}
+
+func (s *BasicStore) StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `
+	SELECT e.streamID, e.streamIndex+1, e.recordedOn
+	FROM   events e
+	       JOIN (SELECT   streamID, MAX(streamIndex) AS streamIndex
+	             FROM     events
+	             WHERE    substr(streamID, 1, length(?)) = ?
+	                      AND streamID > ?
+	             GROUP BY streamID) l
+	       ON e.streamID = l.streamID AND e.streamIndex = l.streamIndex
+	       LEFT JOIN soft_deleted_streams d
+	       ON e.streamID = d.streamID
+	WHERE  e.streamIndex+1 > COALESCE(d.version, 0)
+	ORDER  BY e.streamID
+	LIMIT  ?;`
+	rows, err := s.db.QueryContext(ctx, query, prefix, prefix, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var infos []StreamInfo
+	for rows.Next() {
+		var info StreamInfo
+		var recordedOn string
+		if err := rows.Scan(&info.StreamID, &info.Version, &recordedOn); err != nil {
+			return nil, err
+		}
+		info.LastWrite = parseTime(recordedOn)
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+func (s *BasicStore) IndexAt(t time.Time) (uint64, error) {
+	return s.IndexAtContext(context.Background(), t)
+}
+
+func (s *BasicStore) IndexAtContext(ctx context.Context, t time.Time) (uint64, error) {
+	var index sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT MIN(storeIndex) FROM events WHERE recordedOn >= ?;`, formatTime(t))
+	if err := row.Scan(&index); err != nil {
+		return 0, err
+	}
+	if !index.Valid {
+		return s.VersionContext(ctx, All)
 	}
+	return uint64(index.Int64), nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func queryStreamMetadata(ctx context.Context, q queryRower, streamID string) (StreamMetadata, error) {
+	var meta StreamMetadata
+	row := q.QueryRowContext(ctx, `SELECT maxCount, maxAge, truncateBefore FROM stream_metadata WHERE streamID = ? LIMIT 1;`, streamID)
+	err := row.Scan(&meta.MaxCount, &meta.MaxAge, &meta.TruncateBefore)
+	if err != nil && err != sql.ErrNoRows {
+		return StreamMetadata{}, err
+	}
+	return meta, nil
+}
+
+func setStreamMetadata(ctx context.Context, e execer, streamID string, meta StreamMetadata) error {
+	if meta.IsZero() {
+		_, err := e.ExecContext(ctx, `DELETE FROM stream_metadata WHERE streamID = ?;`, streamID)
+		return err
+	}
+	_, err := e.ExecContext(ctx, `INSERT OR REPLACE INTO stream_metadata (streamID, maxCount, maxAge, truncateBefore) VALUES (?, ?, ?, ?);`,
+		streamID, meta.MaxCount, int64(meta.MaxAge), meta.TruncateBefore)
+	return err
+}
+
+// queryStoreVersion returns the version of $all. Since the positions of deleted
+// records are not reused, the store version at the time of a deletion is
+// considered as well.
+func queryStoreVersion(ctx context.Context, q queryRower) (uint64, error) {
+	row := q.QueryRowContext(ctx, `
+	SELECT COALESCE(MAX(version), 0)
+	FROM   (SELECT MAX(storeIndex)+1 AS version FROM events
+	        UNION ALL
+	        SELECT MAX(storeVersion) AS version FROM deleted_streams);`)
+	var version uint64
+	err := row.Scan(&version)
+	return version, err
+}
+
+// queryStreamVersion returns the version of a stream including the records
+// that are hidden by a soft delete.
+func queryStreamVersion(ctx context.Context, q queryRower, streamID string) (uint64, error) {
+	row := q.QueryRowContext(ctx, `SELECT (streamIndex+1) as version FROM events WHERE streamID = ? ORDER BY streamIndex DESC LIMIT 1;`, streamID)
+	var version uint64
+	err := row.Scan(&version)
+	if err == sql.ErrNoRows {
+		// stream does not exist
+		return 0, nil
+	}
+	return version, err
+}
+
+// queryDeletedVersion returns the version a stream has been soft deleted at or
+// 0 if it has never been soft deleted.
+func queryDeletedVersion(ctx context.Context, q queryRower, streamID string) (uint64, error) {
+	row := q.QueryRowContext(ctx, `SELECT version FROM soft_deleted_streams WHERE streamID = ? LIMIT 1;`, streamID)
+	var version uint64
+	err := row.Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// checkTombstone returns a StreamDeletedError if the stream has been hard deleted.
+func checkTombstone(ctx context.Context, q queryRower, streamID string) error {
+	var exists bool
+	row := q.QueryRowContext(ctx, `SELECT 1 FROM deleted_streams WHERE streamID = ?;`, streamID)
+	if err := row.Scan(&exists); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if exists {
+		return StreamDeletedError{Stream: streamID}
+	}
+	return nil
+}
+
+func recordIDs(ctx context.Context, tx *sql.Tx, streamID string, skip uint64, limit uint64) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM events WHERE streamID = ? AND streamIndex >= ? ORDER BY streamIndex LIMIT ?;`, streamID, int64(skip), int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *BasicStore) SubscribeToStream(streamID string) Subscription {
+	return s.SubscribeToStreamContext(context.Background(), streamID)
+}
+
+func (s *BasicStore) SubscribeToStreamContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, 0)
 }
 
 func (s *BasicStore) SubscribeToStreamFrom(streamID string, version uint64) Subscription {
+	return s.SubscribeToStreamFromContext(context.Background(), streamID, version)
+}
+
+func (s *BasicStore) SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription {
 	return &subscription{
-		loadSlice: s.LoadSlice,
+		ctx:       ctx,
+		loadSlice: s.LoadSliceContext,
 		batchSize: s.batchSize,
 		subscribe: s.publisher.Subscribe,
 		streamID:  streamID,
@@ -232,13 +773,12 @@ func (s *BasicStore) SubscribeToStreamFrom(streamID string, version uint64) Subs
 }
 
 func (s *BasicStore) SubscribeToStreamFromCurrent(streamID string) Subscription {
-	return &subscription{
-		loadSlice: s.LoadSlice,
-		batchSize: s.batchSize,
-		subscribe: s.publisher.Subscribe,
-		streamID:  streamID,
-		from:      s.Version(streamID),
-	}
+	return s.SubscribeToStreamFromCurrentContext(context.Background(), streamID)
+}
+
+func (s *BasicStore) SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription {
+	version, _ := s.VersionContext(ctx, streamID)
+	return s.SubscribeToStreamFromContext(ctx, streamID, version)
 }
 
 func (s *BasicStore) Close() error {
@@ -246,7 +786,7 @@ func (s *BasicStore) Close() error {
 }
 
 func (s *BasicStore) init() error {
-	dir := path.Dir(s.dataSourceName)
+	dir := path.Dir(urn(s.dataSourceName).Path())
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
 		return err
@@ -263,8 +803,35 @@ func (s *BasicStore) init() error {
 	return nil
 }
 
+var defaultBSOptions = [][2]string{
+	{"_foreign_keys", "on"},
+	{"_journal_mode", "WAL"},
+	{"_locking_mode", "NORMAL"},
+	{"_synchronous", "OFF"},
+}
+
+// setOptions adds the default sqlite options to the dsn. Options that are
+// already present in the dsn take precedence over the defaults.
 func setOptions(dsn string) string {
-	return fmt.Sprintf("%s?_foreign_keys=on&_journal_mode=WAL&_locking_mode=NORMAL&_synchronous=OFF", dsn)
+	opts := urn(dsn)
+	vals := opts.Query()
+	var params []string
+	for _, o := range defaultBSOptions {
+		if vals.Get(o[0]) == "" {
+			params = append(params, o[0]+"="+o[1])
+		}
+	}
+	var names []string
+	for n := range vals {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		for _, v := range vals[n] {
+			params = append(params, n+"="+v)
+		}
+	}
+	return fmt.Sprintf("%s?%s", opts.Path(), strings.Join(params, "&"))
 }
 
 const initialize = `
@@ -282,4 +849,38 @@ CREATE TABLE IF NOT EXISTS events (
 
 CREATE UNIQUE INDEX IF NOT EXISTS idx_events_streamID_streamIndex
 ON events (streamID, streamIndex);
+
+CREATE INDEX IF NOT EXISTS idx_events_recordedOn
+ON events (recordedOn);
+
+CREATE INDEX IF NOT EXISTS idx_events_type_storeIndex
+ON events (type, storeIndex);
+
+CREATE TABLE IF NOT EXISTS stream_metadata (
+  streamID TEXT NOT NULL,
+  maxCount INTEGER NOT NULL,
+  maxAge INTEGER NOT NULL,
+  truncateBefore INTEGER NOT NULL,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS deleted_streams (
+  streamID TEXT NOT NULL,
+  storeVersion INTEGER NOT NULL,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS soft_deleted_streams (
+  streamID TEXT NOT NULL,
+  version INTEGER NOT NULL,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+  streamID TEXT,
+  version INTEGER,
+  recordedOn TEXT,
+  data BLOB,
+  PRIMARY KEY (streamID)
+);
 `
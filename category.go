@@ -0,0 +1,70 @@
+package event
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CategoryPrefix is the prefix of the IDs of category streams.
+	CategoryPrefix = "$ce-"
+	// CategorySeparator separates the category of a stream from the rest of its
+	// ID, e.g. the category of "user-123" is "user".
+	CategorySeparator = "-"
+)
+
+// Category returns the category of a stream, i.e. the part of its ID before
+// the first CategorySeparator. Streams without a separator have no category.
+func Category(streamID string) string {
+	if strings.HasPrefix(streamID, "$") {
+		return ""
+	}
+	i := strings.Index(streamID, CategorySeparator)
+	if i <= 0 {
+		return ""
+	}
+	return streamID[:i]
+}
+
+// CategoryStream returns the ID of the category stream of a category. A
+// category stream is a read-only stream that contains the records of all
+// streams of the category. Like in $all, the index of a record within a
+// category stream is its index within $all, so category streams have gaps and
+// their version is the index of their last record + 1.
+func CategoryStream(category string) string {
+	return CategoryPrefix + category
+}
+
+// isCategoryStream reports whether the stream is a category stream and
+// returns its category.
+func isCategoryStream(streamID string) (string, bool) {
+	if !strings.HasPrefix(streamID, CategoryPrefix) {
+		return "", false
+	}
+	category := strings.TrimPrefix(streamID, CategoryPrefix)
+	return category, category != ""
+}
+
+// inCategoryStream reports whether the records of a stream are part of a
+// category stream.
+func inCategoryStream(categoryStreamID string, streamID string) bool {
+	category, ok := isCategoryStream(categoryStreamID)
+	return ok && Category(streamID) == category
+}
+
+// categoryRange returns the bounds [lower, upper) of the IDs of the streams of
+// a category.
+func categoryRange(category string) (string, string) {
+	return category + CategorySeparator, category + string(CategorySeparator[0]+1)
+}
+
+// checkAppendable returns an error for streams that are read-only.
+func checkAppendable(streamID string) error {
+	if _, ok := isCategoryStream(streamID); ok {
+		return fmt.Errorf("records can not be appended to the category stream %s", streamID)
+	}
+	if _, ok := isEventTypeStream(streamID); ok {
+		return fmt.Errorf("records can not be appended to the event type stream %s", streamID)
+	}
+	return nil
+}
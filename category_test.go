@@ -0,0 +1,113 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		streamID string
+		category string
+	}{
+		{streamID: "user-123", category: "user"},
+		{streamID: "user-123-456", category: "user"},
+		{streamID: "user", category: ""},
+		{streamID: "-123", category: ""},
+		{streamID: All, category: ""},
+		{streamID: "$ce-user", category: ""},
+	}
+	for _, test := range tests {
+		if got := Category(test.streamID); got != test.category {
+			t.Errorf("%s want: %q, got: %q", test.streamID, test.category, got)
+		}
+	}
+}
+
+func TestStoreCategory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		exersizeCategory(t, s)
+	})
+}
+
+func exersizeCategory(t *testing.T, s Store) {
+	for _, id := range []string{"user-1", "order-1", "user-2", "order-2", "order-3", "users", "user-1"} {
+		if err := s.Append(id, ExpectAny, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	users := CategoryStream("user")
+
+	if v := s.Version(users); v != 7 {
+		t.Errorf("want: %d, got: %d", 7, v)
+	}
+	if v := s.Version(CategoryStream("invoice")); v != 0 {
+		t.Errorf("want: %d, got: %d", 0, v)
+	}
+
+	type origin struct {
+		Index       uint64
+		Stream      string
+		StreamIndex uint64
+	}
+	var got []origin
+	for _, r := range s.Load(users).Records() {
+		if r.StreamID != users {
+			t.Errorf("want: %s, got: %s", users, r.StreamID)
+		}
+		got = append(got, origin{r.StreamIndex, r.OriginStreamID, r.OriginStreamIndex})
+	}
+	exp := []origin{{0, "user-1", 0}, {2, "user-2", 0}, {6, "user-1", 1}}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+
+	slice, err := s.LoadSlice(CategoryStream("order"), 2, 1)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(slice.Records) != 1 || slice.Records[0].StreamIndex != 3 || slice.Next != 4 || slice.IsEndOfStream {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+
+	if bs, ok := s.(BackwardStore); ok {
+		slice, err := bs.LoadSliceBackward(users, FromEnd, 2)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(slice.Records) != 2 || slice.Records[0].StreamIndex != 6 || slice.Records[1].StreamIndex != 2 || slice.Next != 1 || slice.IsEndOfStream {
+			t.Errorf("unexpected slice: %#v", slice)
+		}
+		slice, err = bs.LoadSliceBackward(users, slice.Next, 2)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(slice.Records) != 1 || slice.Records[0].StreamIndex != 0 || !slice.IsEndOfStream {
+			t.Errorf("unexpected slice: %#v", slice)
+		}
+	}
+
+	if err := s.Append(users, ExpectAny, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Errorf("expected an error since category streams are read-only")
+	}
+
+	sub := s.SubscribeToStreamFromCurrent(users)
+	defer sub.Cancel()
+	records := sub.Records()
+	if err := s.Append("order-4", ExpectAny, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := s.Append("user-3", ExpectAny, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	select {
+	case r := <-records:
+		if r.OriginStreamID != "user-3" || r.StreamIndex != 8 {
+			t.Errorf("unexpected record: %#v", r)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a record")
+	}
+}
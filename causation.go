@@ -0,0 +1,170 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Causation holds the standardized metadata fields that trace the records of a
+// business process. All records of a process share the same correlation ID and
+// the causation ID of a record is the ID of the record (or command) that caused
+// it.
+type Causation struct {
+	CorrelationID string `json:"correlation-id,omitempty"`
+	CausationID   string `json:"causation-id,omitempty"`
+}
+
+// IsZero reports whether the causation is not set.
+func (c Causation) IsZero() bool {
+	return c.CorrelationID == "" && c.CausationID == ""
+}
+
+// CausedBy returns the causation of records that are caused by r. They belong
+// to the correlation of r, or start a correlation with the ID of r if r does
+// not belong to one.
+func CausedBy(r Record) Causation {
+	c := RecordCausation(r)
+	if c.CorrelationID == "" {
+		c.CorrelationID = r.ID
+	}
+	c.CausationID = r.ID
+	return c
+}
+
+// RecordCausation returns the causation of r read from its metadata.
+func RecordCausation(r Record) Causation {
+	var c Causation
+	if len(r.Metadata) > 0 {
+		json.Unmarshal(r.Metadata, &c)
+	}
+	return c
+}
+
+type causationKey struct{}
+
+// WithCausation returns a copy of ctx that carries the causation. Records that
+// are encoded with this context via Codec.EncodeContext get the causation.
+func WithCausation(ctx context.Context, c Causation) context.Context {
+	return context.WithValue(ctx, causationKey{}, c)
+}
+
+// CausationFromContext returns the causation carried by ctx.
+func CausationFromContext(ctx context.Context) (Causation, bool) {
+	c, ok := ctx.Value(causationKey{}).(Causation)
+	return c, ok
+}
+
+// WithCausationMetadata adds the causation to the metadata of a record. Fields
+// that are already part of the metadata are kept. Metadata that is not a JSON
+// object is left as it is.
+func WithCausationMetadata(c Causation) RecordMutation {
+	return func(r *Record) {
+		if c.IsZero() {
+			return
+		}
+		md := map[string]json.RawMessage{}
+		if len(r.Metadata) > 0 {
+			if err := json.Unmarshal(r.Metadata, &md); err != nil {
+				return
+			}
+		}
+		set := func(key string, value string) {
+			if _, ok := md[key]; ok || value == "" {
+				return
+			}
+			md[key], _ = Encode(value)
+		}
+		set("correlation-id", c.CorrelationID)
+		set("causation-id", c.CausationID)
+		if data, err := Encode(md); err == nil {
+			r.Metadata = data
+		}
+	}
+}
+
+// CausationNode is a record together with the records it caused.
+type CausationNode struct {
+	Record Record
+	Caused []*CausationNode
+}
+
+// LoadCorrelation loads all records of a correlation in the order of $all.
+// Queries for the correlation can be sped up by indexing the path
+// $.correlation-id of the metadata.
+func LoadCorrelation(ctx context.Context, store QueryableStore, correlationID string) (Records, error) {
+	var recs Records
+	skip := uint64(0)
+	for {
+		slice, err := store.QueryContext(ctx, skip, 1000, MetadataEquals("$.correlation-id", correlationID))
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, slice.Records...)
+		if slice.IsEndOfStream {
+			return recs, nil
+		}
+		skip = slice.Next
+	}
+}
+
+// LoadCorrelationGraph loads all records of a correlation as causation trees.
+// The roots are the records whose cause is not a record of the correlation,
+// e.g. the records that have been caused by a command.
+func LoadCorrelationGraph(ctx context.Context, store QueryableStore, correlationID string) ([]*CausationNode, error) {
+	recs, err := LoadCorrelation(ctx, store, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	roots, _ := causationGraph(recs)
+	return roots, nil
+}
+
+// LoadCausationTree loads the tree of records that have been caused by r,
+// directly or indirectly.
+func LoadCausationTree(ctx context.Context, store QueryableStore, r Record) (*CausationNode, error) {
+	root := &CausationNode{Record: r}
+	if r.ID == "" {
+		return root, nil
+	}
+	recs, err := LoadCorrelation(ctx, store, CausedBy(r).CorrelationID)
+	if err != nil {
+		return nil, err
+	}
+	roots, nodes := causationGraph(recs)
+	if node, ok := nodes[r.ID]; ok {
+		root.Caused = node.Caused
+		return root, nil
+	}
+	// r is not part of its correlation (e.g. a record of another store), so the
+	// records it caused are roots.
+	for _, n := range roots {
+		if RecordCausation(n.Record).CausationID == r.ID {
+			root.Caused = append(root.Caused, n)
+		}
+	}
+	return root, nil
+}
+
+// causationGraph links the records by their causation. It returns the roots
+// and the nodes by the IDs of their records.
+func causationGraph(recs Records) ([]*CausationNode, map[string]*CausationNode) {
+	nodes := map[string]*CausationNode{}
+	var all []*CausationNode
+	for _, r := range recs {
+		n := &CausationNode{Record: r}
+		all = append(all, n)
+		if r.ID != "" {
+			nodes[r.ID] = n
+		}
+	}
+	var roots []*CausationNode
+	for _, n := range all {
+		cause, ok := nodes[RecordCausation(n.Record).CausationID]
+		if !ok || cause == n {
+			roots = append(roots, n)
+			continue
+		}
+		cause.Caused = append(cause.Caused, n)
+	}
+	return roots, nodes
+}
@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type causationTestEvent struct {
+	ID string `json:"id"`
+}
+
+func TestCodecEncodeContext(t *testing.T) {
+	codec := NewCodec()
+	codec.Register("test", causationTestEvent{})
+	ctx := WithCausation(context.Background(), Causation{CorrelationID: "cmd-1", CausationID: "cmd-1"})
+
+	tests := []struct {
+		name     string
+		muts     []RecordMutation
+		metadata string
+	}{
+		{name: "empty", metadata: `{"causation-id":"cmd-1","correlation-id":"cmd-1"}`},
+		{name: "merged", muts: []RecordMutation{WithMetadata(map[string]string{"user-name": "admin"})}, metadata: `{"causation-id":"cmd-1","correlation-id":"cmd-1","user-name":"admin"}`},
+		{name: "explicit", muts: []RecordMutation{WithMetadata(Causation{CorrelationID: "other"})}, metadata: `{"causation-id":"cmd-1","correlation-id":"other"}`},
+		{name: "no-object", muts: []RecordMutation{WithMetadata([]int{1})}, metadata: `[1]`},
+	}
+	for _, test := range tests {
+		r, err := codec.EncodeContext(ctx, causationTestEvent{ID: "a"}, test.muts...)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if string(r.Metadata) != test.metadata {
+			t.Errorf("%s want: %s, got: %s", test.name, test.metadata, r.Metadata)
+		}
+	}
+
+	r, err := codec.Encode(causationTestEvent{ID: "a"})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(r.Metadata) != 0 {
+		t.Errorf("expected no metadata, but got: %s", r.Metadata)
+	}
+}
+
+func TestCausedBy(t *testing.T) {
+	root := Record{ID: "a"}
+	if c, exp := CausedBy(root), (Causation{CorrelationID: "a", CausationID: "a"}); c != exp {
+		t.Errorf("want: %v, got: %v", exp, c)
+	}
+	child := Record{ID: "b", Metadata: json.RawMessage(`{"correlation-id":"a","causation-id":"a"}`)}
+	if c, exp := CausedBy(child), (Causation{CorrelationID: "a", CausationID: "b"}); c != exp {
+		t.Errorf("want: %v, got: %v", exp, c)
+	}
+}
+
+func TestBasicStoreCausation(t *testing.T) {
+	s, err := NewBasicStore(":memory:")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+
+	codec := NewCodec()
+	codec.Register("test", causationTestEvent{})
+	emit := func(ctx context.Context, streamID string, ids ...string) Records {
+		var events Events
+		for _, id := range ids {
+			events = append(events, causationTestEvent{ID: id})
+		}
+		recs, err := codec.EncodeAllContext(ctx, events)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if err := s.Append(streamID, ExpectAny, recs); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		return recs
+	}
+
+	// a command starts the process, sagas react to the resulting records
+	ctx := WithCausation(context.Background(), Causation{CorrelationID: "cmd-1", CausationID: "cmd-1"})
+	a := emit(ctx, "order-1", "a")[0]
+	emit(context.Background(), "order-2", "x")
+	bc := emit(WithCausation(ctx, CausedBy(a)), "stock-1", "b", "c")
+	emit(WithCausation(ctx, CausedBy(bc[0])), "invoice-1", "d")
+	emit(WithCausation(context.Background(), Causation{CorrelationID: "cmd-2", CausationID: "cmd-2"}), "order-3", "y")
+
+	type tree struct {
+		ID     string
+		Caused []tree
+	}
+	var toTree func(n *CausationNode) tree
+	toTree = func(n *CausationNode) tree {
+		t := tree{ID: n.Record.ID}
+		for _, c := range n.Caused {
+			t.Caused = append(t.Caused, toTree(c))
+		}
+		return t
+	}
+
+	roots, err := LoadCorrelationGraph(context.Background(), s, "cmd-1")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	var got []tree
+	for _, r := range roots {
+		got = append(got, toTree(r))
+	}
+	exp := []tree{{ID: "a", Caused: []tree{{ID: "b", Caused: []tree{{ID: "d"}}}, {ID: "c"}}}}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+
+	root, err := LoadCausationTree(context.Background(), s, bc[0])
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got, exp := toTree(root), (tree{ID: "b", Caused: []tree{{ID: "d"}}}); !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+}
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -13,11 +14,19 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/cognicraft/pubsub"
-	"github.com/cognicraft/sqlutil"
 )
 
 var (
-	_ Store = (*ChunkedStore)(nil)
+	_ Store               = (*ChunkedStore)(nil)
+	_ ContextStore        = (*ChunkedStore)(nil)
+	_ AppendResultStore   = (*ChunkedStore)(nil)
+	_ DeletableStore      = (*ChunkedStore)(nil)
+	_ StreamMetadataStore = (*ChunkedStore)(nil)
+	_ ListableStore       = (*ChunkedStore)(nil)
+	_ BackwardStore       = (*ChunkedStore)(nil)
+	_ TimeIndexedStore    = (*ChunkedStore)(nil)
+	_ QueryableStore      = (*ChunkedStore)(nil)
+	_ SnapshotStore       = (*ChunkedStore)(nil)
 )
 
 const (
@@ -55,50 +64,90 @@ func NewChunkedStore(dataSourceName string) (*ChunkedStore, error) {
 }
 
 type ChunkedStore struct {
-	dir       string
-	batchSize uint64
-	chunkSize uint64
-	mu        sync.Mutex
-	index     *sql.DB
-	publisher pubsub.Publisher
+	dir         string
+	batchSize   uint64
+	chunkSize   uint64
+	mu          sync.Mutex
+	index       *sql.DB
+	publisher   pubsub.Publisher
+	pathIndexes []string // the statements that create the path indexes of a chunk
 }
 
 func (s *ChunkedStore) Version(streamID string) uint64 {
-	qVersion := s.index.QueryRow(`SELECT version FROM streams WHERE id = ? LIMIT 1;`, streamID)
-	var version uint64
-	qVersion.Scan(&version)
+	version, _ := s.VersionContext(context.Background(), streamID)
 	return version
 }
 
+func (s *ChunkedStore) VersionContext(ctx context.Context, streamID string) (uint64, error) {
+	if category, ok := isCategoryStream(streamID); ok {
+		return s.categoryVersion(ctx, category)
+	}
+	if typ, ok := isEventTypeStream(streamID); ok {
+		return s.eventTypeVersion(ctx, typ)
+	}
+	version, err := s.version(ctx, streamID)
+	if err != nil || All == streamID {
+		return version, err
+	}
+	deleted, err := queryDeletedVersion(ctx, s.index, streamID)
+	if err != nil {
+		return 0, err
+	}
+	return visibleVersion(version, deleted), nil
+}
+
+// version returns the version of a stream including the records that are
+// hidden by a soft delete.
+func (s *ChunkedStore) version(ctx context.Context, streamID string) (uint64, error) {
+	qVersion := s.index.QueryRowContext(ctx, `SELECT version FROM streams WHERE id = ? LIMIT 1;`, streamID)
+	var version uint64
+	err := qVersion.Scan(&version)
+	if err == sql.ErrNoRows {
+		// stream does not exist
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
 func (s *ChunkedStore) Load(streamID string) RecordStream {
 	return s.LoadFrom(streamID, 0)
 }
 
+func (s *ChunkedStore) LoadContext(ctx context.Context, streamID string) RecordStream {
+	return s.LoadFromContext(ctx, streamID, 0)
+}
+
 func (s *ChunkedStore) LoadFrom(streamID string, skip uint64) RecordStream {
-	out := make(chan Record)
-	go func() {
-		defer close(out)
-		next := skip
-		for {
-			slice, err := s.LoadSlice(streamID, next, s.batchSize)
-			if err != nil {
-				return
-			}
-			for _, e := range slice.Records {
-				out <- e
-			}
-			if slice.IsEndOfStream {
-				return
-			}
-			next = slice.Next
-		}
-	}()
-	return out
+	return s.LoadFromContext(context.Background(), streamID, skip)
+}
+
+func (s *ChunkedStore) LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream {
+	return loadFrom(ctx, s.LoadSliceContext, streamID, skip, s.batchSize)
 }
 
 func (s *ChunkedStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceContext(context.Background(), streamID, skip, limit)
+}
+
+func (s *ChunkedStore) LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSlice(ctx, streamID, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *ChunkedStore) loadSlice(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	first, err := s.first(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	skip = max(skip, first)
+
 	var c *readChunk
-	var err error
 	res := &Slice{
 		StreamID: streamID,
 		From:     skip,
@@ -107,10 +156,19 @@ func (s *ChunkedStore) LoadSlice(streamID string, skip uint64, limit uint64) (*S
 	nLimit := limit
 	for {
 		var chunkID int
-		if All == streamID {
+		if typ, ok := isEventTypeStream(streamID); ok {
+			// continue with the next chunk that holds records of the type
+			var next sql.NullInt64
+			q := s.index.QueryRowContext(ctx, `SELECT MIN(chunkID) FROM chunk_types WHERE type = ? AND chunkID >= ?;`, typ, nSkip/s.chunkSize)
+			if err := q.Scan(&next); err != nil || !next.Valid {
+				break
+			}
+			chunkID = int(next.Int64)
+			nSkip = max(nSkip, uint64(chunkID)*s.chunkSize)
+		} else if isStoreIndexed(streamID) {
 			chunkID = int(nSkip) / int(s.chunkSize)
 		} else {
-			qChunkID := s.index.QueryRow(`SELECT chunkID FROM chunk_streams WHERE streamID = ? AND ? BETWEEN minIndex AND maxIndex LIMIT 1;`, streamID, nSkip)
+			qChunkID := s.index.QueryRowContext(ctx, `SELECT chunkID FROM chunk_streams WHERE streamID = ? AND ? BETWEEN minIndex AND maxIndex LIMIT 1;`, streamID, nSkip)
 			qChunkID.Scan(&chunkID)
 		}
 		c, err = s.readChunk(chunkID)
@@ -118,22 +176,29 @@ func (s *ChunkedStore) LoadSlice(streamID string, skip uint64, limit uint64) (*S
 			// no chunk exists
 			break
 		}
-		records, err := c.loadRecords(streamID, nSkip, nLimit+1)
+		records, err := c.loadRecords(ctx, streamID, nSkip, nLimit+1)
+		c.close()
 		if err != nil {
 			return nil, err
 		}
 		if len(records) == 0 {
+			if isStoreIndexed(streamID) {
+				// the remaining records of the chunk might have been deleted or
+				// belong to other categories or types
+				nSkip = uint64(chunkID+1) * s.chunkSize
+				continue
+			}
 			// no new records could be found
 			break
 		}
 		res.Records = append(res.Records, records...)
-		if len(res.Records) >= int(limit) {
+		if len(res.Records) > int(limit) {
 			// enough records have been found
 			break
 		}
-		nEvents := uint64(len(records))
-		nSkip += nEvents
-		nLimit -= nEvents
+		// positions within $all might be missing due to deleted streams
+		nSkip = records[len(records)-1].StreamIndex + 1
+		nLimit -= uint64(len(records))
 	}
 	res.IsEndOfStream = (len(res.Records) <= int(limit))
 	if !res.IsEndOfStream {
@@ -145,26 +210,308 @@ func (s *ChunkedStore) LoadSlice(streamID string, skip uint64, limit uint64) (*S
 	return res, nil
 }
 
+func (s *ChunkedStore) LoadBackward(streamID string) RecordStream {
+	return s.LoadBackwardContext(context.Background(), streamID)
+}
+
+func (s *ChunkedStore) LoadBackwardContext(ctx context.Context, streamID string) RecordStream {
+	return loadBackward(ctx, s.LoadSliceBackwardContext, streamID, s.batchSize)
+}
+
+func (s *ChunkedStore) LoadSliceBackward(streamID string, from uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceBackwardContext(context.Background(), streamID, from, limit)
+}
+
+func (s *ChunkedStore) LoadSliceBackwardContext(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSliceBackward(ctx, streamID, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *ChunkedStore) loadSliceBackward(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	version, err := s.VersionContext(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	first, err := s.first(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs Records
+	if version > 0 && from >= first {
+		index := min(from, version-1)
+		for uint64(len(recs)) <= limit {
+			var chunkID int
+			if typ, ok := isEventTypeStream(streamID); ok {
+				// continue with the previous chunk that holds records of the type
+				var prev sql.NullInt64
+				q := s.index.QueryRowContext(ctx, `SELECT MAX(chunkID) FROM chunk_types WHERE type = ? AND chunkID <= ?;`, typ, index/s.chunkSize)
+				if err := q.Scan(&prev); err != nil || !prev.Valid {
+					break
+				}
+				chunkID = int(prev.Int64)
+				index = min(index, uint64(chunkID+1)*s.chunkSize-1)
+			} else if isStoreIndexed(streamID) {
+				chunkID = int(index / s.chunkSize)
+			} else {
+				qChunkID := s.index.QueryRowContext(ctx, `SELECT chunkID FROM chunk_streams WHERE streamID = ? AND ? BETWEEN minIndex AND maxIndex LIMIT 1;`, streamID, index)
+				if err := qChunkID.Scan(&chunkID); err != nil {
+					break
+				}
+			}
+			c, err := s.readChunk(chunkID)
+			if err != nil {
+				// no chunk exists
+				break
+			}
+			records, err := c.loadRecordsBackward(ctx, streamID, index, first, limit+1-uint64(len(recs)))
+			c.close()
+			if err != nil {
+				return nil, err
+			}
+			if len(records) == 0 {
+				if isStoreIndexed(streamID) && chunkID > 0 {
+					// the remaining records of the chunk might have been deleted
+					// or belong to other categories or types
+					index = uint64(chunkID)*s.chunkSize - 1
+					continue
+				}
+				break
+			}
+			recs = append(recs, records...)
+			last := records[len(records)-1].StreamIndex
+			if last <= first {
+				break
+			}
+			index = last - 1
+		}
+	}
+	return backwardSlice(streamID, from, limit, recs, first), nil
+}
+
+// first returns the index of the first record of a stream that is retained by
+// its metadata and not hidden by a soft delete.
+func (s *ChunkedStore) first(ctx context.Context, streamID string) (uint64, error) {
+	if All == streamID {
+		return 0, nil
+	}
+	meta, err := s.StreamMetadataContext(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := queryDeletedVersion(ctx, s.index, streamID)
+	if err != nil || meta.IsZero() {
+		return deleted, err
+	}
+	version, err := s.VersionContext(ctx, streamID)
+	if err != nil {
+		return 0, err
+	}
+	first := max(meta.first(version), deleted)
+	if meta.MaxAge <= 0 || first >= version {
+		return first, nil
+	}
+	cutoff := meta.cutoff(time.Now().UTC())
+	chunkIDs, err := s.chunkIDs(ctx, `SELECT chunkID FROM chunk_streams WHERE streamID = ? AND maxIndex >= ? ORDER BY minIndex;`, streamID, first)
+	if err != nil {
+		return 0, err
+	}
+	for _, chunkID := range chunkIDs {
+		c, err := s.readChunk(chunkID)
+		if err != nil {
+			return 0, err
+		}
+		index, ok, err := c.indexSince(ctx, streamID, first, cutoff)
+		c.close()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return index, nil
+		}
+	}
+	return version, nil
+}
+
+func (s *ChunkedStore) IndexAt(t time.Time) (uint64, error) {
+	return s.IndexAtContext(context.Background(), t)
+}
+
+func (s *ChunkedStore) IndexAtContext(ctx context.Context, t time.Time) (uint64, error) {
+	var chunkIDs []int
+	rows, err := s.index.QueryContext(ctx, `SELECT id FROM chunks ORDER BY id;`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var chunkID int
+		if err := rows.Scan(&chunkID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		chunkIDs = append(chunkIDs, chunkID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, chunkID := range chunkIDs {
+		c, err := s.readChunk(chunkID)
+		if err != nil {
+			return 0, err
+		}
+		index, found, err := c.indexAt(ctx, t)
+		c.close()
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return index, nil
+		}
+	}
+	return s.VersionContext(ctx, All)
+}
+
+func (s *ChunkedStore) Query(skip uint64, limit uint64, conditions ...Condition) (*Slice, error) {
+	return s.QueryContext(context.Background(), skip, limit, conditions...)
+}
+
+func (s *ChunkedStore) QueryContext(ctx context.Context, skip uint64, limit uint64, conditions ...Condition) (*Slice, error) {
+	var recs Records
+	for chunkID := int(skip / s.chunkSize); uint64(len(recs)) <= limit; chunkID++ {
+		query, args, err := queryStatement(max(skip, uint64(chunkID)*s.chunkSize), limit+1-uint64(len(recs)), conditions)
+		if err != nil {
+			return nil, err
+		}
+		c, err := s.readChunk(chunkID)
+		if err != nil {
+			// no chunk exists
+			break
+		}
+		records, err := c.query(ctx, query, args)
+		c.close()
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, records...)
+	}
+	return querySlice(skip, limit, recs), nil
+}
+
+// IndexPath creates the index within all existing chunks and within all chunks
+// that will be created.
+func (s *ChunkedStore) IndexPath(field Field, path string) error {
+	stmt, err := jsonIndex(field, path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.index.Exec(`INSERT OR IGNORE INTO path_indexes (field, path) VALUES (?, ?);`, string(field), path); err != nil {
+		return err
+	}
+	ids, err := s.chunkIDs(context.Background(), `SELECT id FROM chunks;`)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		c, err := s.readChunk(id)
+		if err != nil {
+			return err
+		}
+		_, err = c.db.Exec(stmt)
+		c.close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, existing := range s.pathIndexes {
+		if existing == stmt {
+			return nil
+		}
+	}
+	s.pathIndexes = append(s.pathIndexes, stmt)
+	return nil
+}
+
 func (s *ChunkedStore) Append(streamID string, expectedVersion uint64, records Records) error {
+	return s.AppendContext(context.Background(), streamID, expectedVersion, records)
+}
+
+func (s *ChunkedStore) AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	_, err := s.AppendWithResult(ctx, streamID, expectedVersion, records)
+	return err
+}
+
+// AppendWithResult appends records to a stream. Records that do not fit into
+// the last chunk are committed chunk by chunk, so an append that spans several
+// chunks is not atomic: if a later chunk can not be written the records of the
+// earlier chunks remain. The ctx is therefore only honoured until the first
+// chunk is written, an append that has started writing is completed regardless
+// of ctx.
+func (s *ChunkedStore) AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
 	if All == streamID {
-		return s.appendToStore(expectedVersion, records)
+		return s.appendToStore(ctx, expectedVersion, records)
+	}
+	if err := checkAppendable(streamID); err != nil {
+		return AppendResult{}, err
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		return AppendResult{}, err
+	}
+
 	c, err := s.lastChunk()
 	if err != nil {
-		return err
+		return AppendResult{}, err
 	}
+	defer func() {
+		if c != nil {
+			c.close()
+		}
+	}()
 
-	streamVersion := s.Version(streamID)
-	if streamVersion != expectedVersion {
-		return OptimisticConcurrencyError{Stream: streamID, Expected: expectedVersion, Actual: streamVersion}
+	if err := s.checkTombstone(ctx, streamID); err != nil {
+		return AppendResult{}, err
+	}
+	streamVersion, err := s.version(ctx, streamID)
+	if err != nil {
+		return AppendResult{}, err
 	}
+	deleted, err := queryDeletedVersion(ctx, s.index, streamID)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	if err := checkExpectedStreamVersion(streamID, expectedVersion, streamVersion, deleted); err != nil {
+		dup, dErr := checkIdempotency(streamID, expectedVersion, streamVersion, deleted, records, func(skip uint64, limit uint64) ([]string, error) {
+			slice, err := s.loadSlice(ctx, streamID, skip, limit)
+			if err != nil {
+				return nil, err
+			}
+			var ids []string
+			for _, r := range slice.Records {
+				ids = append(ids, r.ID)
+			}
+			return ids, nil
+		})
+		if dup {
+			return AppendResult{StreamID: streamID, Version: streamVersion}, nil
+		}
+		if dErr != nil {
+			return AppendResult{}, dErr
+		}
+		return AppendResult{}, err
+	}
+	res := AppendResult{StreamID: streamID, Version: streamVersion}
 
-	storeVersion := s.Version(All)
 	for i, r := range records {
-		storeVersion++
 		r.StreamID = streamID
 		r.StreamIndex = streamVersion
 		streamVersion++
@@ -174,12 +521,17 @@ func (s *ChunkedStore) Append(streamID string, expectedVersion uint64, records R
 		records[i] = r
 	}
 
+	if err := ctx.Err(); err != nil {
+		return AppendResult{}, err
+	}
+	write := context.Background()
 	toAppend := records
 	for len(toAppend) > 0 {
 		rem := int(c.remaining())
 		if rem == 0 {
+			c.close()
 			if c, err = s.nextChunk(); err != nil {
-				return err
+				return AppendResult{}, err
 			}
 			continue
 		}
@@ -191,43 +543,70 @@ func (s *ChunkedStore) Append(streamID string, expectedVersion uint64, records R
 			next = toAppend[:rem]
 			toAppend = toAppend[rem:]
 		}
-		sv, err := c.append(next)
+		sv, err := c.append(write, next)
 		if err != nil {
-			return err
+			return AppendResult{}, err
 		}
-		if err := s.updateIndex(sv, c.id, streamID, next[0].StreamIndex, next[len(next)-1].StreamIndex); err != nil {
-			return err
+		if err := s.updateIndex(write, sv, c.id, next); err != nil {
+			return AppendResult{}, err
+		}
+		for i, r := range next {
+			res.add(r.StreamIndex, sv-uint64(len(next)-i), r.RecordedOn)
 		}
 	}
-	if err == nil {
-		s.publisher.Publish(topicAppend, streamID)
-	}
-	return nil
+	s.publisher.Publish(topicAppend, streamID)
+	return res, nil
 }
 
-func (s *ChunkedStore) appendToStore(expectedVersion uint64, records Records) error {
+func (s *ChunkedStore) appendToStore(ctx context.Context, expectedVersion uint64, records Records) (AppendResult, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := ctx.Err(); err != nil {
+		return AppendResult{}, err
+	}
+
 	c, err := s.lastChunk()
 	if err != nil {
-		return err
+		return AppendResult{}, err
 	}
+	defer func() {
+		if c != nil {
+			c.close()
+		}
+	}()
 
-	storeVersion := s.Version(All)
-	if storeVersion != expectedVersion {
-		return OptimisticConcurrencyError{Stream: All, Expected: expectedVersion, Actual: storeVersion}
+	storeVersion, err := s.VersionContext(ctx, All)
+	if err != nil {
+		return AppendResult{}, err
 	}
+	if err := checkExpectedVersion(All, expectedVersion, storeVersion); err != nil {
+		return AppendResult{}, err
+	}
+	for i, e := range records {
+		if e.StreamIndex != storeVersion+uint64(i) {
+			return AppendResult{}, fmt.Errorf("record %d has store index %d but expected %d", i, e.StreamIndex, storeVersion+uint64(i))
+		}
+	}
+	res := AppendResult{StreamID: All, Version: storeVersion}
 
 	updatedStreams := map[string]bool{}
 
+	// like AppendWithResult, records are committed chunk by chunk and ctx is
+	// only honoured until the first chunk is written.
+	if err := ctx.Err(); err != nil {
+		return AppendResult{}, err
+	}
+	write := context.Background()
+
 	for _, streamRecords := range partitionByOriginStreamID(records) {
 		toAppend := streamRecords
 		for len(toAppend) > 0 {
 			rem := int(c.remaining())
 			if rem == 0 {
+				c.close()
 				if c, err = s.nextChunk(); err != nil {
-					return err
+					return AppendResult{}, err
 				}
 				continue
 			}
@@ -239,38 +618,330 @@ func (s *ChunkedStore) appendToStore(expectedVersion uint64, records Records) er
 				next = toAppend[:rem]
 				toAppend = toAppend[rem:]
 			}
-			sv, err := c.append(next)
+			sv, err := c.append(write, next)
 			if err != nil {
+				return AppendResult{}, err
+			}
+			if err := s.updateIndex(write, sv, c.id, next); err != nil {
+				return AppendResult{}, err
+			}
+			updatedStreams[next[0].StreamID] = true
+			for i, r := range next {
+				storeIndex := sv - uint64(len(next)-i)
+				res.add(storeIndex, storeIndex, r.RecordedOn)
+			}
+		}
+	}
+
+	for streamID := range updatedStreams {
+		s.publisher.Publish(topicAppend, streamID)
+	}
+	return res, nil
+}
+
+func (s *ChunkedStore) DeleteStream(streamID string, expectedVersion uint64, hard bool) error {
+	return s.DeleteStreamContext(context.Background(), streamID, expectedVersion, hard)
+}
+
+// DeleteStreamContext deletes a stream. A hard delete removes the stream from
+// the index and tombstones it in a single transaction before its records are
+// removed from their chunks one by one. If removing the records fails, the
+// remaining ones are still part of $all, but they are no longer part of the
+// stream.
+func (s *ChunkedStore) DeleteStreamContext(ctx context.Context, streamID string, expectedVersion uint64, hard bool) error {
+	if All == streamID {
+		return fmt.Errorf("%s can not be deleted", All)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTombstone(ctx, streamID); err != nil {
+		return err
+	}
+	streamVersion, err := s.version(ctx, streamID)
+	if err != nil {
+		return err
+	}
+	deleted, err := queryDeletedVersion(ctx, s.index, streamID)
+	if err != nil {
+		return err
+	}
+	if err := checkExpectedStreamVersion(streamID, expectedVersion, streamVersion, deleted); err != nil {
+		return err
+	}
+
+	if !hard {
+		return transact(ctx, s.index, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE streamID = ?;`, streamID); err != nil {
 				return err
 			}
-			if err := s.updateIndex(sv, c.id, next[0].StreamID, next[0].StreamIndex, next[len(next)-1].StreamIndex); err != nil {
+			_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO soft_deleted_streams (streamID, version) VALUES (?, ?);`, streamID, streamVersion)
+			return err
+		})
+	}
+
+	chunkIDs, err := s.chunkIDs(ctx, `SELECT chunkID FROM chunk_streams WHERE streamID = ?;`, streamID)
+	if err != nil {
+		return err
+	}
+
+	err = transact(ctx, s.index, func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`DELETE FROM chunk_streams WHERE streamID = ?;`,
+			`DELETE FROM streams WHERE id = ?;`,
+			`DELETE FROM snapshots WHERE streamID = ?;`,
+			`DELETE FROM stream_metadata WHERE streamID = ?;`,
+			`DELETE FROM soft_deleted_streams WHERE streamID = ?;`,
+			`INSERT OR REPLACE INTO tombstones (streamID) VALUES (?);`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, streamID); err != nil {
 				return err
 			}
-			updatedStreams[next[0].StreamID] = true
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if err == nil {
-		for streamID := range updatedStreams {
-			s.publisher.Publish(topicAppend, streamID)
+	// the stream is gone, its records are removed regardless of ctx.
+	for _, chunkID := range chunkIDs {
+		c, err := s.readChunk(chunkID)
+		if err != nil {
+			return err
+		}
+		err = c.deleteRecords(context.Background(), streamID)
+		c.close()
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (s *ChunkedStore) SubscribeToStream(streamID string) Subscription {
-	return &subscription{
-		loadSlice: s.LoadSlice,
-		batchSize: s.batchSize,
-		subscribe: s.publisher.Subscribe,
-		streamID:  streamID,
-		from:      0,
+func (s *ChunkedStore) LoadSnapshot(streamID string) (Snapshot, error) {
+	return s.LoadSnapshotContext(context.Background(), streamID)
+}
+
+func (s *ChunkedStore) LoadSnapshotContext(ctx context.Context, streamID string) (Snapshot, error) {
+	var snapshot Snapshot
+	var recordedOn string
+	var data []byte
+	row := s.index.QueryRowContext(ctx, `SELECT streamID, version, recordedOn, data FROM snapshots WHERE streamID = ? LIMIT 1;`, streamID)
+	err := row.Scan(&snapshot.StreamID, &snapshot.Version, &recordedOn, &data)
+	if err == sql.ErrNoRows {
+		return Snapshot{}, nil
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.RecordedOn = parseTime(recordedOn)
+	snapshot.Data = data
+	return snapshot, nil
+}
+
+func (s *ChunkedStore) SaveSnapshot(snapshot Snapshot) error {
+	return s.SaveSnapshotContext(context.Background(), snapshot)
+}
+
+func (s *ChunkedStore) SaveSnapshotContext(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := s.VersionContext(ctx, snapshot.StreamID)
+	if err != nil {
+		return err
+	}
+	if err := checkSnapshot(snapshot, version); err != nil {
+		return err
+	}
+	if snapshot.RecordedOn.IsZero() {
+		snapshot.RecordedOn = time.Now().UTC()
+	}
+	_, err = s.index.ExecContext(ctx, `
+	INSERT INTO snapshots (streamID, version, recordedOn, data) VALUES (?, ?, ?, ?)
+	ON CONFLICT (streamID) DO UPDATE SET version = excluded.version, recordedOn = excluded.recordedOn, data = excluded.data
+	WHERE excluded.version >= snapshots.version;`,
+		snapshot.StreamID, snapshot.Version, formatTime(snapshot.RecordedOn), []byte(snapshot.Data))
+	return err
+}
+
+func (s *ChunkedStore) StreamMetadata(streamID string) (StreamMetadata, error) {
+	return s.StreamMetadataContext(context.Background(), streamID)
+}
+
+func (s *ChunkedStore) StreamMetadataContext(ctx context.Context, streamID string) (StreamMetadata, error) {
+	return queryStreamMetadata(ctx, s.index, streamID)
+}
+
+func (s *ChunkedStore) SetStreamMetadata(streamID string, meta StreamMetadata) error {
+	return s.SetStreamMetadataContext(context.Background(), streamID, meta)
+}
+
+func (s *ChunkedStore) SetStreamMetadataContext(ctx context.Context, streamID string, meta StreamMetadata) error {
+	if All == streamID {
+		return fmt.Errorf("metadata can not be set for %s", All)
+	}
+	return setStreamMetadata(ctx, s.index, streamID, meta)
+}
+
+func (s *ChunkedStore) Streams(prefix string, after string, limit int) ([]StreamInfo, error) {
+	return s.StreamsContext(context.Background(), prefix, after, limit)
+}
+
+func (s *ChunkedStore) StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	query := `
+	SELECT   s.id, s.version
+	FROM     streams s
+	         LEFT JOIN soft_deleted_streams d
+	         ON s.id = d.streamID
+	WHERE    s.id != ?
+	         AND substr(s.id, 1, length(?)) = ?
+	         AND s.id > ?
+	         AND s.version > COALESCE(d.version, 0)
+	ORDER BY s.id
+	LIMIT    ?;`
+	rows, err := s.index.QueryContext(ctx, query, All, prefix, prefix, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	var infos []StreamInfo
+	for rows.Next() {
+		var info StreamInfo
+		if err := rows.Scan(&info.StreamID, &info.Version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, info := range infos {
+		last, err := s.lastRecord(ctx, info.StreamID, info.Version)
+		if err != nil {
+			return nil, err
+		}
+		infos[i].LastWrite = last.RecordedOn
+	}
+	return infos, nil
+}
+
+// categoryVersion returns the version of a category stream, i.e. the index of
+// the last record of the category within $all + 1.
+func (s *ChunkedStore) categoryVersion(ctx context.Context, category string) (uint64, error) {
+	lower, upper := categoryRange(category)
+	var chunkID sql.NullInt64
+	q := s.index.QueryRowContext(ctx, `SELECT MAX(chunkID) FROM chunk_streams WHERE streamID >= ? AND streamID < ?;`, lower, upper)
+	if err := q.Scan(&chunkID); err != nil || !chunkID.Valid {
+		return 0, err
+	}
+	c, err := s.readChunk(int(chunkID.Int64))
+	if err != nil {
+		return 0, err
+	}
+	defer c.close()
+	var version sql.NullInt64
+	q = c.db.QueryRowContext(ctx, `SELECT MAX(storeIndex)+1 FROM events WHERE streamID >= ? AND streamID < ?;`, lower, upper)
+	err = q.Scan(&version)
+	return uint64(version.Int64), err
+}
+
+// eventTypeVersion returns the version of an event type stream, i.e. the index
+// of the last record of the type within $all + 1.
+func (s *ChunkedStore) eventTypeVersion(ctx context.Context, typ string) (uint64, error) {
+	rows, err := s.index.QueryContext(ctx, `SELECT chunkID FROM chunk_types WHERE type = ? ORDER BY chunkID DESC;`, typ)
+	if err != nil {
+		return 0, err
+	}
+	var chunkIDs []int
+	for rows.Next() {
+		var chunkID int
+		if err := rows.Scan(&chunkID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		chunkIDs = append(chunkIDs, chunkID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	// the records of the type might have been deleted from the last chunks.
+	for _, chunkID := range chunkIDs {
+		c, err := s.readChunk(chunkID)
+		if err != nil {
+			return 0, err
+		}
+		var version sql.NullInt64
+		q := c.db.QueryRowContext(ctx, `SELECT MAX(storeIndex)+1 FROM events WHERE type = ?;`, typ)
+		err = q.Scan(&version)
+		c.close()
+		if err != nil {
+			return 0, err
+		}
+		if version.Valid {
+			return uint64(version.Int64), nil
+		}
+	}
+	return 0, nil
+}
+
+// lastRecord loads the last record of a stream regardless of its retention.
+func (s *ChunkedStore) lastRecord(ctx context.Context, streamID string, version uint64) (Record, error) {
+	var chunkID int
+	q := s.index.QueryRowContext(ctx, `SELECT chunkID FROM chunk_streams WHERE streamID = ? AND maxIndex = ? LIMIT 1;`, streamID, version-1)
+	if err := q.Scan(&chunkID); err != nil {
+		return Record{}, err
+	}
+	c, err := s.readChunk(chunkID)
+	if err != nil {
+		return Record{}, err
+	}
+	defer c.close()
+	records, err := c.loadRecords(ctx, streamID, version-1, 1)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(records) == 0 {
+		return Record{}, fmt.Errorf("could not find the last record of %s", streamID)
 	}
+	return records[0], nil
+}
+
+// checkTombstone returns a StreamDeletedError if the stream has been hard deleted.
+func (s *ChunkedStore) checkTombstone(ctx context.Context, streamID string) error {
+	var exists bool
+	q := s.index.QueryRowContext(ctx, `SELECT 1 FROM tombstones WHERE streamID = ?;`, streamID)
+	if err := q.Scan(&exists); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if exists {
+		return StreamDeletedError{Stream: streamID}
+	}
+	return nil
+}
+
+func (s *ChunkedStore) SubscribeToStream(streamID string) Subscription {
+	return s.SubscribeToStreamContext(context.Background(), streamID)
+}
+
+func (s *ChunkedStore) SubscribeToStreamContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, 0)
 }
 
 func (s *ChunkedStore) SubscribeToStreamFrom(streamID string, version uint64) Subscription {
+	return s.SubscribeToStreamFromContext(context.Background(), streamID, version)
+}
+
+func (s *ChunkedStore) SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription {
 	return &subscription{
-		loadSlice: s.LoadSlice,
+		ctx:       ctx,
+		loadSlice: s.LoadSliceContext,
 		batchSize: s.batchSize,
 		subscribe: s.publisher.Subscribe,
 		streamID:  streamID,
@@ -279,17 +950,16 @@ func (s *ChunkedStore) SubscribeToStreamFrom(streamID string, version uint64) Su
 }
 
 func (s *ChunkedStore) SubscribeToStreamFromCurrent(streamID string) Subscription {
-	return &subscription{
-		loadSlice: s.LoadSlice,
-		batchSize: s.batchSize,
-		subscribe: s.publisher.Subscribe,
-		streamID:  streamID,
-		from:      s.Version(streamID),
-	}
+	return s.SubscribeToStreamFromCurrentContext(context.Background(), streamID)
+}
+
+func (s *ChunkedStore) SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription {
+	version, _ := s.VersionContext(ctx, streamID)
+	return s.SubscribeToStreamFromContext(ctx, streamID, version)
 }
 
 func (s *ChunkedStore) Close() error {
-	return nil
+	return s.index.Close()
 }
 
 func (s *ChunkedStore) init() error {
@@ -306,7 +976,10 @@ func (s *ChunkedStore) init() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := s.loadPathIndexes(); err != nil {
+		return err
+	}
+	return s.indexTypes()
 }
 
 func (s *ChunkedStore) directory() string {
@@ -341,28 +1014,104 @@ func (s *ChunkedStore) nextChunk() (*writeChunk, error) {
 	return s.writechunk(nIdx)
 }
 
-func (s *ChunkedStore) updateIndex(storeVersion uint64, chunkID int, streamID string, minIndex uint64, maxIndex uint64) error {
-	err := sqlutil.Transact(s.index, func(tx *sql.Tx) error {
-		qMinIndex := tx.QueryRow("SELECT minIndex FROM chunk_streams WHERE chunkID = ? AND streamID = ? LIMIT 1;", chunkID, streamID)
+// updateIndex indexes records of a single stream that have been appended to a
+// chunk.
+func (s *ChunkedStore) updateIndex(ctx context.Context, storeVersion uint64, chunkID int, records Records) error {
+	streamID := records[0].StreamID
+	minIndex := records[0].StreamIndex
+	maxIndex := records[len(records)-1].StreamIndex
+	err := transact(ctx, s.index, func(tx *sql.Tx) error {
+		qMinIndex := tx.QueryRowContext(ctx, "SELECT minIndex FROM chunk_streams WHERE chunkID = ? AND streamID = ? LIMIT 1;", chunkID, streamID)
 		var sMinIndex uint64
 		if err := qMinIndex.Scan(&sMinIndex); err == nil {
 			minIndex = min(minIndex, sMinIndex)
 		}
-		if _, err := tx.Exec(`INSERT OR REPLACE INTO chunk_streams (chunkID, streamID, minIndex, maxIndex) VALUES (?, ?, ?, ?);`,
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO chunk_streams (chunkID, streamID, minIndex, maxIndex) VALUES (?, ?, ?, ?);`,
 			chunkID, streamID, minIndex, maxIndex); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(`INSERT OR REPLACE INTO streams (id, version) VALUES (?, ?);`, streamID, maxIndex+1); err != nil {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO streams (id, version) VALUES (?, ?);`, streamID, maxIndex+1); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(`INSERT OR REPLACE INTO streams (id, version) VALUES (?, ?);`, All, storeVersion); err != nil {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO streams (id, version) VALUES (?, ?);`, All, storeVersion); err != nil {
 			return err
 		}
+		for _, r := range records {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO chunk_types (type, chunkID) VALUES (?, ?);`, r.Type, chunkID); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	return err
 }
 
+// chunkIDs returns the ids of the chunks that are selected by query.
+func (s *ChunkedStore) chunkIDs(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := s.index.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// loadPathIndexes loads the statements that create the path indexes of a
+// chunk.
+func (s *ChunkedStore) loadPathIndexes() error {
+	rows, err := s.index.Query(`SELECT field, path FROM path_indexes;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var field, path string
+		if err := rows.Scan(&field, &path); err != nil {
+			return err
+		}
+		stmt, err := jsonIndex(Field(field), path)
+		if err != nil {
+			return err
+		}
+		s.pathIndexes = append(s.pathIndexes, stmt)
+	}
+	return rows.Err()
+}
+
+// indexTypes adds the record types of chunks that have been written before
+// record types have been indexed to the index.
+func (s *ChunkedStore) indexTypes() error {
+	ids, err := s.chunkIDs(context.Background(), `SELECT id FROM chunks WHERE id NOT IN (SELECT chunkID FROM chunk_types);`)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		c, err := s.readChunk(id)
+		if err != nil {
+			return err
+		}
+		types, err := c.types()
+		c.close()
+		if err != nil {
+			return err
+		}
+		for _, typ := range types {
+			if _, err := s.index.Exec(`INSERT OR IGNORE INTO chunk_types (type, chunkID) VALUES (?, ?);`, typ, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *ChunkedStore) writechunk(id int) (*writeChunk, error) {
 	var exists bool
 	q := s.index.QueryRow(`SELECT 1 FROM chunks WHERE id = ? AND status = 'active';`, id)
@@ -409,16 +1158,29 @@ func (c *writeChunk) init() error {
 	if err != nil {
 		return err
 	}
+	for _, stmt := range c.store.pathIndexes {
+		if _, err := c.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (c *writeChunk) close() error {
+	return c.db.Close()
+}
+
 func (c *writeChunk) version() uint64 {
+	version := uint64(c.id) * c.store.chunkSize
 	vQ := c.db.QueryRow(`SELECT (storeIndex+1) as version FROM events ORDER BY storeIndex DESC LIMIT 1;`)
-	var version uint64
-	err := vQ.Scan(&version)
-	if err != nil {
-		// stream does not exist
-		return uint64(c.id) * c.store.chunkSize
+	var chunkVersion uint64
+	if err := vQ.Scan(&chunkVersion); err == nil {
+		version = max(version, chunkVersion)
+	}
+	// the last records of the chunk might have been deleted, but their
+	// positions must not be reused.
+	if storeVersion, err := c.store.VersionContext(context.Background(), All); err == nil {
+		version = max(version, storeVersion)
 	}
 	return version
 }
@@ -428,15 +1190,15 @@ func (c *writeChunk) remaining() uint64 {
 	return uint64(c.id+1)*c.store.chunkSize - sv
 }
 
-func (c *writeChunk) append(records Records) (uint64, error) {
+func (c *writeChunk) append(ctx context.Context, records Records) (uint64, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	storeVersion := c.version()
-	err := sqlutil.Transact(c.db, func(tx *sql.Tx) error {
+	err := transact(ctx, c.db, func(tx *sql.Tx) error {
 		for _, r := range records {
 			storeIndex := storeVersion
 			storeVersion++
-			if _, err := tx.Exec(`INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			if _, err := tx.ExecContext(ctx, `INSERT INTO events (storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
 				storeIndex, r.StreamID, r.StreamIndex, formatTime(r.RecordedOn), r.ID, r.Type, []byte(r.Data), []byte(r.Metadata)); err != nil {
 				return err
 			}
@@ -462,7 +1224,11 @@ func (c *readChunk) init() error {
 	return nil
 }
 
-func (c *readChunk) loadRecords(streamID string, skip uint64, limit uint64) (Records, error) {
+func (c *readChunk) close() error {
+	return c.db.Close()
+}
+
+func (c *readChunk) loadRecords(ctx context.Context, streamID string, skip uint64, limit uint64) (Records, error) {
 	var rows *sql.Rows
 	var err error
 	if All == streamID {
@@ -472,7 +1238,27 @@ func (c *readChunk) loadRecords(streamID string, skip uint64, limit uint64) (Rec
 		WHERE  storeIndex >= ?
 		ORDER  BY storeIndex
 		LIMIT  ?;`
-		rows, err = c.db.Query(query, int64(skip), int64(limit)+1)
+		rows, err = c.db.QueryContext(ctx, query, int64(skip), int64(limit)+1)
+	} else if category, ok := isCategoryStream(streamID); ok {
+		lower, upper := categoryRange(category)
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID >= ?
+		       AND streamID < ?
+		       AND storeIndex >= ?
+		ORDER  BY storeIndex
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, streamID, lower, upper, int64(skip), int64(limit)+1)
+	} else if typ, ok := isEventTypeStream(streamID); ok {
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  type = ?
+		       AND storeIndex >= ?
+		ORDER  BY storeIndex
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, streamID, typ, int64(skip), int64(limit)+1)
 	} else {
 		query := `
 		SELECT streamID, streamIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
@@ -481,8 +1267,66 @@ func (c *readChunk) loadRecords(streamID string, skip uint64, limit uint64) (Rec
 		       AND streamIndex >= ?
 		ORDER  BY streamIndex
 		LIMIT  ?;`
-		rows, err = c.db.Query(query, streamID, int64(skip), int64(limit)+1)
+		rows, err = c.db.QueryContext(ctx, query, streamID, int64(skip), int64(limit)+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return records(rows)
+}
+
+func (c *readChunk) loadRecordsBackward(ctx context.Context, streamID string, from uint64, first uint64, limit uint64) (Records, error) {
+	var rows *sql.Rows
+	var err error
+	if All == streamID {
+		query := `
+		SELECT '$all', storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, int64(from), int64(limit))
+	} else if category, ok := isCategoryStream(streamID); ok {
+		lower, upper := categoryRange(category)
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID >= ?
+		       AND streamID < ?
+		       AND storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, streamID, lower, upper, int64(from), int64(limit))
+	} else if typ, ok := isEventTypeStream(streamID); ok {
+		query := `
+		SELECT ?, storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  type = ?
+		       AND storeIndex <= ?
+		ORDER  BY storeIndex DESC
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, streamID, typ, int64(from), int64(limit))
+	} else {
+		query := `
+		SELECT streamID, streamIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  streamID = ?
+		       AND streamIndex <= ?
+		       AND streamIndex >= ?
+		ORDER  BY streamIndex DESC
+		LIMIT  ?;`
+		rows, err = c.db.QueryContext(ctx, query, streamID, int64(from), int64(first), int64(limit))
+	}
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+	return records(rows)
+}
+
+func (c *readChunk) query(ctx context.Context, query string, args []interface{}) (Records, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -490,6 +1334,48 @@ func (c *readChunk) loadRecords(streamID string, skip uint64, limit uint64) (Rec
 	return records(rows)
 }
 
+func (c *readChunk) types() ([]string, error) {
+	rows, err := c.db.Query(`SELECT DISTINCT type FROM events;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var typ string
+		if err := rows.Scan(&typ); err != nil {
+			return nil, err
+		}
+		types = append(types, typ)
+	}
+	return types, rows.Err()
+}
+
+func (c *readChunk) indexAt(ctx context.Context, t time.Time) (uint64, bool, error) {
+	var index sql.NullInt64
+	row := c.db.QueryRowContext(ctx, `SELECT MIN(storeIndex) FROM events WHERE recordedOn >= ?;`, formatTime(t))
+	if err := row.Scan(&index); err != nil {
+		return 0, false, err
+	}
+	return uint64(index.Int64), index.Valid, nil
+}
+
+// indexSince returns the index of the first record of a stream at or after skip
+// that has been recorded at or after t.
+func (c *readChunk) indexSince(ctx context.Context, streamID string, skip uint64, t time.Time) (uint64, bool, error) {
+	var index sql.NullInt64
+	row := c.db.QueryRowContext(ctx, `SELECT MIN(streamIndex) FROM events WHERE streamID = ? AND streamIndex >= ? AND recordedOn >= ?;`, streamID, int64(skip), formatTime(t))
+	if err := row.Scan(&index); err != nil {
+		return 0, false, err
+	}
+	return uint64(index.Int64), index.Valid, nil
+}
+
+func (c *readChunk) deleteRecords(ctx context.Context, streamID string) error {
+	_, err := c.db.ExecContext(ctx, `DELETE FROM events WHERE streamID = ?;`, streamID)
+	return err
+}
+
 func partitionByOriginStreamID(rs Records) []Records {
 	var out []Records
 	var currentOriginStreamID string
@@ -532,6 +1418,45 @@ CREATE TABLE IF NOT EXISTS chunk_streams (
   maxIndex INTEGER,
   PRIMARY KEY (chunkID, streamID)
 );
+
+CREATE TABLE IF NOT EXISTS stream_metadata (
+  streamID TEXT,
+  maxCount INTEGER,
+  maxAge INTEGER,
+  truncateBefore INTEGER,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS tombstones (
+  streamID TEXT,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS soft_deleted_streams (
+  streamID TEXT,
+  version INTEGER,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+  streamID TEXT,
+  version INTEGER,
+  recordedOn TEXT,
+  data BLOB,
+  PRIMARY KEY (streamID)
+);
+
+CREATE TABLE IF NOT EXISTS chunk_types (
+  type TEXT,
+  chunkID INTEGER,
+  PRIMARY KEY (type, chunkID)
+);
+
+CREATE TABLE IF NOT EXISTS path_indexes (
+  field TEXT,
+  path TEXT,
+  PRIMARY KEY (field, path)
+);
 `
 
 const initialize_chunk = `
@@ -549,4 +1474,10 @@ CREATE TABLE IF NOT EXISTS events (
 
 CREATE UNIQUE INDEX IF NOT EXISTS idx_events_streamID_streamIndex
 ON events (streamID, streamIndex);
+
+CREATE INDEX IF NOT EXISTS idx_events_recordedOn
+ON events (recordedOn);
+
+CREATE INDEX IF NOT EXISTS idx_events_type_storeIndex
+ON events (type, storeIndex);
 `
@@ -1,10 +1,43 @@
 package event
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 )
 
+func TestChunkedStoreSliceOnChunkBoundary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewChunkedStore(dir + "?chunk-size=2")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 4; i++ {
+		if err := s.Append("foo", uint64(i), Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	// the slice ends on the last record of the first chunk
+	for _, streamID := range []string{"foo", All} {
+		slice, err := s.LoadSlice(streamID, 0, 2)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(slice.Records) != 2 || slice.Next != 2 || slice.IsEndOfStream {
+			t.Errorf("unexpected slice of %s: %#v", streamID, slice)
+		}
+	}
+}
+
 func TestPartitionByOriginStreamID(t *testing.T) {
 	tests := []struct {
 		name string
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cognicraft/event"
@@ -17,7 +18,8 @@ func main() {
 	serveCommand := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveBind := serveCommand.String("bind", "127.0.0.1:4711", "addresss")
 	serveCommand.Usage = func() {
-		fmt.Println("usage: es serve [<options>] <database-connection>")
+		fmt.Println("usage: es serve [<options>] <data-source-name>")
+		fmt.Printf("supported schemes: %s\n", strings.Join(event.Schemes(), ", "))
 		serveCommand.PrintDefaults()
 	}
 
@@ -31,17 +33,28 @@ func main() {
 
 	replicateCommand := flag.NewFlagSet("replicate", flag.ExitOnError)
 	replicateSource := replicateCommand.String("source", "", "URL of a stream.")
-	replicateTarget := replicateCommand.String("target", "", "Database.")
+	replicateTarget := replicateCommand.String("target", "", "Data source name of the target store (e.g. sqlite:events.db).")
 	replicateFollow := replicateCommand.Bool("follow", false, "follow")
 	replicateCommand.Usage = func() {
 		fmt.Println("usage: es replicate [<options>]")
 		replicateCommand.PrintDefaults()
 	}
 
+	var queryConditions []event.Condition
+	queryCommand := flag.NewFlagSet("query", flag.ExitOnError)
+	queryCommand.Var(&conditionsFlag{field: event.DataField, conditions: &queryConditions}, "data", "Condition on the data of a record (e.g. $.name=Jane). May be repeated.")
+	queryCommand.Var(&conditionsFlag{field: event.MetadataField, conditions: &queryConditions}, "metadata", "Condition on the metadata of a record (e.g. $.correlation=transaction:1). May be repeated.")
+	queryFrom := queryCommand.Uint64("from", 0, "from version")
+	queryCommand.Usage = func() {
+		fmt.Println("usage: es query [<options>] <data-source-name>")
+		queryCommand.PrintDefaults()
+	}
+
 	if len(os.Args) == 1 {
 		fmt.Println("usage: es <command> [<args>]")
 		fmt.Println("The most commonly used es commands are: ")
-		fmt.Println("  replicate Replicates a stream into a Database.")
+		fmt.Println("  query     Finds records by values of their data or metadata.")
+		fmt.Println("  replicate Replicates a stream into a store.")
 		fmt.Println("  serve     Provides HTTP access to an event-store.")
 		fmt.Println("  stream    Copies stream to out.")
 		return
@@ -54,6 +67,8 @@ func main() {
 		streamCommand.Parse(os.Args[2:])
 	case "replicate":
 		replicateCommand.Parse(os.Args[2:])
+	case "query":
+		queryCommand.Parse(os.Args[2:])
 	default:
 		fmt.Printf("%q is not a valid command.\n", os.Args[1])
 		os.Exit(2)
@@ -76,11 +91,17 @@ func main() {
 	case replicateCommand.Parsed():
 		target := *replicateTarget
 		replicate(*replicateSource, target, *replicateFollow)
+	case queryCommand.Parsed():
+		if len(queryCommand.Args()) == 0 {
+			queryCommand.Usage()
+			return
+		}
+		query(queryCommand.Args()[0], *queryFrom, queryConditions)
 	}
 }
 
 func serve(bind string, dsn string) {
-	store, err := event.NewBasicStore(dsn)
+	store, err := event.Open(dsn)
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
@@ -117,7 +138,10 @@ func stream(stream string, follow bool, skip uint64) {
 }
 
 func replicate(source string, target string, follow bool) {
-	store, err := event.NewBasicStore(target)
+	store, err := event.Open(target)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
 	defer store.Close()
 	vAll := store.Version(event.All)
 
@@ -142,3 +166,61 @@ func replicate(source string, target string, follow bool) {
 		}
 	}
 }
+
+func query(dsn string, skip uint64, conditions []event.Condition) {
+	store, err := event.Open(dsn)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	defer store.Close()
+	qs, ok := store.(event.QueryableStore)
+	if !ok {
+		log.Fatalf("%s does not support queries", dsn)
+	}
+	for {
+		slice, err := qs.Query(skip, 100, conditions...)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		for _, e := range slice.Records {
+			bs, err := json.Marshal(e)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+			}
+			fmt.Printf("%s\n", string(bs))
+		}
+		if slice.IsEndOfStream {
+			return
+		}
+		skip = slice.Next
+	}
+}
+
+// conditionsFlag collects conditions of the form path=value on a field. Values
+// that are valid JSON (e.g. 42 or true) are compared as such, all others are
+// compared as strings.
+type conditionsFlag struct {
+	field      event.Field
+	conditions *[]event.Condition
+}
+
+func (f *conditionsFlag) String() string {
+	return ""
+}
+
+func (f *conditionsFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("condition %q is not of the form path=value", s)
+	}
+	var value interface{} = s[i+1:]
+	var v interface{}
+	if err := json.Unmarshal([]byte(s[i+1:]), &v); err == nil {
+		switch v.(type) {
+		case string, float64, bool:
+			value = v
+		}
+	}
+	*f.conditions = append(*f.conditions, event.Condition{Field: f.field, Path: s[:i], Value: value})
+	return nil
+}
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"time"
@@ -76,9 +77,15 @@ type Codec struct {
 }
 
 func (c *Codec) EncodeAll(events Events, muts ...RecordMutation) (Records, error) {
+	return c.EncodeAllContext(context.Background(), events, muts...)
+}
+
+// EncodeAllContext encodes events like EncodeAll and adds the causation carried
+// by ctx to their metadata.
+func (c *Codec) EncodeAllContext(ctx context.Context, events Events, muts ...RecordMutation) (Records, error) {
 	var recs Records
 	for _, evt := range events {
-		rec, err := c.Encode(evt, muts...)
+		rec, err := c.EncodeContext(ctx, evt, muts...)
 		if err != nil {
 			return nil, err
 		}
@@ -88,6 +95,12 @@ func (c *Codec) EncodeAll(events Events, muts ...RecordMutation) (Records, error
 }
 
 func (c *Codec) Encode(event Event, muts ...RecordMutation) (Record, error) {
+	return c.EncodeContext(context.Background(), event, muts...)
+}
+
+// EncodeContext encodes an event like Encode and adds the causation carried by
+// ctx to its metadata.
+func (c *Codec) EncodeContext(ctx context.Context, event Event, muts ...RecordMutation) (Record, error) {
 	name, data, err := c.Marshal(json.Marshal, event)
 	if err != nil {
 		return Record{}, err
@@ -102,6 +115,9 @@ func (c *Codec) Encode(event Event, muts ...RecordMutation) (Record, error) {
 	for _, mut := range muts {
 		mut(&r)
 	}
+	if causation, ok := CausationFromContext(ctx); ok {
+		WithCausationMetadata(causation)(&r)
+	}
 	return r, nil
 }
 
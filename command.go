@@ -0,0 +1,133 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultExecuteAttempts = 3
+)
+
+// Command changes an aggregate by recording events. Commands are executed
+// again if the aggregate has been changed concurrently, so they must not have
+// side effects besides changing the aggregate.
+type Command func(a Aggregate) error
+
+// ConflictFunc reports whether the changes of a command conflict with the
+// events that have been saved concurrently since the aggregate has been loaded.
+type ConflictFunc func(changes Events, intervening Events) bool
+
+// ExecuteOption configures the execution of a command.
+type ExecuteOption func(*execution)
+
+// Attempts sets the maximum number of times a command is executed. The default
+// is 3.
+func Attempts(n int) ExecuteOption {
+	return func(e *execution) {
+		e.attempts = n
+	}
+}
+
+// Backoff sets the delay before the next attempt. The attempt that failed is
+// passed, starting with 1. By default commands are retried immediately.
+func Backoff(fn func(attempt int) time.Duration) ExecuteOption {
+	return func(e *execution) {
+		e.backoff = fn
+	}
+}
+
+// ExponentialBackoff doubles the delay after each attempt starting with base
+// until it reaches limit.
+func ExponentialBackoff(base time.Duration, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < limit; i++ {
+			d *= 2
+		}
+		if d > limit {
+			return limit
+		}
+		return d
+	}
+}
+
+// CheckConflicts sets a function that decides whether a command may be retried
+// after a concurrent change. If the changes conflict with the intervening events
+// the OptimisticConcurrencyError is returned without retrying.
+func CheckConflicts(fn ConflictFunc) ExecuteOption {
+	return func(e *execution) {
+		e.conflicts = fn
+	}
+}
+
+// WithRecordMutations sets the mutations that are applied to the records when
+// the aggregate is saved.
+func WithRecordMutations(muts ...RecordMutation) ExecuteOption {
+	return func(e *execution) {
+		e.muts = muts
+	}
+}
+
+type execution struct {
+	attempts  int
+	backoff   func(attempt int) time.Duration
+	conflicts ConflictFunc
+	muts      []RecordMutation
+}
+
+// Execute loads an aggregate, executes a command on it and saves the changes.
+// Commands on aggregates that do not exist yet are executed on an empty
+// aggregate. If the aggregate has been changed concurrently it is reloaded and
+// the command is executed again.
+func (r *Repository) Execute(id string, cmd Command, opts ...ExecuteOption) (Aggregate, error) {
+	return r.ExecuteContext(context.Background(), id, cmd, opts...)
+}
+
+// ExecuteContext loads an aggregate, executes a command on it and saves the
+// changes. Retries stop once the ctx is done.
+func (r *Repository) ExecuteContext(ctx context.Context, id string, cmd Command, opts ...ExecuteOption) (Aggregate, error) {
+	e := execution{
+		attempts: defaultExecuteAttempts,
+	}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	for attempt := 1; ; attempt++ {
+		a, err := r.LoadContext(ctx, id)
+		if _, ok := err.(AggregateNotFoundError); ok {
+			a, err = r.create(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd(a); err != nil {
+			return nil, err
+		}
+		changes := a.Changes()
+		err = r.SaveContext(ctx, a, e.muts...)
+		if err == nil {
+			return a, nil
+		}
+		oce, ok := err.(OptimisticConcurrencyError)
+		if !ok || attempt >= e.attempts {
+			return nil, err
+		}
+		if e.conflicts != nil {
+			intervening, err := r.codec.DecodeAll(oce.Intervening)
+			if err != nil {
+				return nil, err
+			}
+			if e.conflicts(changes, intervening) {
+				return nil, oce
+			}
+		}
+		if e.backoff != nil {
+			select {
+			case <-time.After(e.backoff(attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepositoryExecute(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	repo := NewRepository(s, codec, func() Aggregate {
+		return &repositoryTestCounter{ChangeRecorder: NewChangeRecorder()}
+	})
+	add := func(amount int) Command {
+		return func(a Aggregate) error {
+			c := a.(*repositoryTestCounter)
+			c.id = "counter-1"
+			c.add(amount)
+			return nil
+		}
+	}
+
+	a, err := repo.Execute("counter-1", add(1))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if c := a.(*repositoryTestCounter); c.version != 1 || c.sum != 1 {
+		t.Errorf("unexpected aggregate: %#v", c)
+	}
+
+	// concurrent adds a record on behalf of another writer the first n times it
+	// is executed.
+	concurrent := func(n int, amount int) (Command, *int) {
+		executions := 0
+		return func(a Aggregate) error {
+			executions++
+			if executions <= n {
+				if _, err := repo.Execute("counter-1", add(10)); err != nil {
+					t.Fatalf("expected no error, but got: %v", err)
+				}
+			}
+			return add(amount)(a)
+		}, &executions
+	}
+
+	cmd, executions := concurrent(1, 2)
+	var delays []int
+	a, err = repo.Execute("counter-1", cmd, Backoff(func(attempt int) time.Duration {
+		delays = append(delays, attempt)
+		return time.Millisecond
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if c := a.(*repositoryTestCounter); c.version != 3 || c.sum != 13 {
+		t.Errorf("unexpected aggregate: %#v", c)
+	}
+	if *executions != 2 || !reflect.DeepEqual([]int{1}, delays) {
+		t.Errorf("unexpected executions: %d, delays: %v", *executions, delays)
+	}
+
+	cmd, executions = concurrent(3, 2)
+	_, err = repo.Execute("counter-1", cmd, Attempts(2))
+	if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an OptimisticConcurrencyError, but got: %v", err)
+	}
+	if *executions != 2 {
+		t.Errorf("want: %d, got: %d", 2, *executions)
+	}
+
+	var gotChanges, gotIntervening Events
+	cmd, executions = concurrent(1, 3)
+	_, err = repo.Execute("counter-1", cmd, CheckConflicts(func(changes Events, intervening Events) bool {
+		gotChanges, gotIntervening = changes, intervening
+		return true
+	}))
+	if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an OptimisticConcurrencyError, but got: %v", err)
+	}
+	if *executions != 1 {
+		t.Errorf("want: %d, got: %d", 1, *executions)
+	}
+	if exp := (Events{repositoryTestAdded{Counter: "counter-1", Amount: 3}}); !reflect.DeepEqual(exp, gotChanges) {
+		t.Errorf("want: %v, got: %v", exp, gotChanges)
+	}
+	if exp := (Events{repositoryTestAdded{Counter: "counter-1", Amount: 10}}); !reflect.DeepEqual(exp, gotIntervening) {
+		t.Errorf("want: %v, got: %v", exp, gotIntervening)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	exp := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, d := range exp {
+		if got := backoff(i + 1); got != d {
+			t.Errorf("attempt %d want: %v, got: %v", i+1, d, got)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package event
+
+import (
+	"context"
+	"reflect"
+)
+
+// LoadIntervening loads the records that have been appended to the stream of an
+// OptimisticConcurrencyError since its expected version and adds them to the
+// error. Errors of appends that expected any version or an existing stream have
+// no intervening records.
+func LoadIntervening(ctx context.Context, store Store, err OptimisticConcurrencyError) (OptimisticConcurrencyError, error) {
+	var from uint64
+	switch err.Expected {
+	case ExpectAny, ExpectStreamExists:
+		return err, nil
+	case ExpectNoStream:
+		from = 0
+	default:
+		from = err.Expected
+	}
+	var recs Records
+	it := IterateContext(ctx, store, err.Stream, from)
+	for it.Next() {
+		recs = append(recs, it.Record())
+	}
+	if iErr := it.Err(); iErr != nil {
+		return err, iErr
+	}
+	err.Intervening = recs
+	return err, nil
+}
+
+// NewConflictRules creates ConflictRules without any conflicts.
+func NewConflictRules() *ConflictRules {
+	return &ConflictRules{
+		conflicts: map[reflect.Type]map[reflect.Type]bool{},
+	}
+}
+
+// ConflictRules decide by their types whether changes conflict with the events
+// that have been saved concurrently. Events only conflict if a rule has been
+// declared for their types, all other events are compatible:
+//
+//	rules := event.NewConflictRules().
+//		ConflictsWith(NameChanged{}, NameChanged{}, Deleted{})
+//	repository := event.NewRepository(store, codec, create, event.AutoRebase(rules.Conflicts))
+type ConflictRules struct {
+	conflicts map[reflect.Type]map[reflect.Type]bool
+}
+
+// ConflictsWith declares that events of the type of e conflict with events of
+// the types of others and vice versa.
+func (c *ConflictRules) ConflictsWith(e Event, others ...Event) *ConflictRules {
+	t := reflect.TypeOf(e)
+	for _, other := range others {
+		o := reflect.TypeOf(other)
+		c.add(t, o)
+		c.add(o, t)
+	}
+	return c
+}
+
+func (c *ConflictRules) add(t reflect.Type, o reflect.Type) {
+	if c.conflicts[t] == nil {
+		c.conflicts[t] = map[reflect.Type]bool{}
+	}
+	c.conflicts[t][o] = true
+}
+
+// Conflicts reports whether any of the changes conflicts with any of the
+// intervening events. It is a ConflictFunc.
+func (c *ConflictRules) Conflicts(changes Events, intervening Events) bool {
+	for _, change := range changes {
+		conflicts := c.conflicts[reflect.TypeOf(change)]
+		for _, e := range intervening {
+			if conflicts[reflect.TypeOf(e)] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type conflictTestReset struct {
+	ID      string `json:"id"`
+	Counter string `json:"counter"`
+}
+
+func TestLoadIntervening(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	appendRecord := func(expected uint64, id string) error {
+		return s.Append("stream-1", expected, Records{{ID: id, Type: "test", Data: json.RawMessage(`{}`)}})
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if err := appendRecord(uint64(i), id); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+
+	tests := []struct {
+		expected uint64
+		ids      []string
+	}{
+		{expected: 1, ids: []string{"b", "c"}},
+		{expected: ExpectNoStream, ids: []string{"a", "b", "c"}},
+	}
+	for _, test := range tests {
+		err := appendRecord(test.expected, "x")
+		oce, ok := err.(OptimisticConcurrencyError)
+		if !ok {
+			t.Fatalf("expected an OptimisticConcurrencyError, but got: %v", err)
+		}
+		if oce, err = LoadIntervening(context.Background(), s, oce); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		var ids []string
+		for _, r := range oce.Intervening {
+			ids = append(ids, r.ID)
+		}
+		if !reflect.DeepEqual(test.ids, ids) {
+			t.Errorf("%s want: %v, got: %v", FormatExpectedVersion(test.expected), test.ids, ids)
+		}
+	}
+}
+
+func TestConflictRules(t *testing.T) {
+	rules := NewConflictRules().ConflictsWith(conflictTestReset{}, repositoryTestAdded{}, conflictTestReset{})
+	tests := []struct {
+		changes     Events
+		intervening Events
+		conflicts   bool
+	}{
+		{Events{repositoryTestAdded{}}, Events{repositoryTestAdded{}}, false},
+		{Events{repositoryTestAdded{}}, Events{repositoryTestAdded{}, conflictTestReset{}}, true},
+		{Events{conflictTestReset{}}, Events{repositoryTestAdded{}}, true},
+		{Events{conflictTestReset{}}, Events{conflictTestReset{}}, true},
+		{Events{conflictTestReset{}}, nil, false},
+	}
+	for i, test := range tests {
+		if got := rules.Conflicts(test.changes, test.intervening); got != test.conflicts {
+			t.Errorf("%d want: %t, got: %t", i, test.conflicts, got)
+		}
+	}
+}
+
+func TestRepositoryAutoRebase(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	codec.Register("reset", conflictTestReset{})
+	create := func() Aggregate {
+		return &repositoryTestCounter{ChangeRecorder: NewChangeRecorder()}
+	}
+	rules := NewConflictRules().ConflictsWith(conflictTestReset{}, repositoryTestAdded{}, conflictTestReset{})
+	repo := NewRepository(s, codec, create, AutoRebase(rules.Conflicts))
+
+	c := &repositoryTestCounter{id: "counter-1", ChangeRecorder: NewChangeRecorder()}
+	c.add(1)
+	if err := repo.Save(c); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	load := func() *repositoryTestCounter {
+		a, err := repo.Load("counter-1")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		return a.(*repositoryTestCounter)
+	}
+
+	// compatible changes are rebased onto the intervening events
+	first, second := load(), load()
+	first.add(2)
+	second.add(3)
+	if err := repo.Save(first); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := repo.Save(second); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if second.version != 3 || second.sum != 6 || len(second.Changes()) != 0 {
+		t.Errorf("unexpected aggregate: %#v", second)
+	}
+	if c := load(); c.version != 3 || c.sum != 6 {
+		t.Errorf("unexpected aggregate: %#v", c)
+	}
+
+	// conflicting changes are rejected together with the intervening records
+	first, second = load(), load()
+	first.Record(conflictTestReset{Counter: "counter-1"})
+	first.Mutate(conflictTestReset{Counter: "counter-1"})
+	second.add(4)
+	if err := repo.Save(first); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	err := repo.Save(second)
+	oce, ok := err.(OptimisticConcurrencyError)
+	if !ok {
+		t.Fatalf("expected an OptimisticConcurrencyError, but got: %v", err)
+	}
+	if oce.Expected != 3 || oce.Actual != 4 || len(oce.Intervening) != 1 || oce.Intervening[0].Type != "reset" {
+		t.Errorf("unexpected error: %#v", oce)
+	}
+	if len(second.Changes()) != 1 || second.version != 4 {
+		t.Errorf("expected the aggregate to be unchanged, but got: %#v", second)
+	}
+}
+
+func TestRepositoryAutoRebaseOrder(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	codec.Register("reset", conflictTestReset{})
+	create := func() Aggregate {
+		return &repositoryTestCounter{ChangeRecorder: NewChangeRecorder()}
+	}
+	repo := NewRepository(s, codec, create, AutoRebase(func(Events, Events) bool { return false }))
+
+	c := &repositoryTestCounter{id: "counter-1", ChangeRecorder: NewChangeRecorder()}
+	c.add(1)
+	if err := repo.Save(c); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	load := func() *repositoryTestCounter {
+		a, err := repo.Load("counter-1")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		return a.(*repositoryTestCounter)
+	}
+
+	// the rebased changes are applied after the intervening reset
+	first, second := load(), load()
+	first.Record(conflictTestReset{Counter: "counter-1"})
+	first.Mutate(conflictTestReset{Counter: "counter-1"})
+	second.add(4)
+	if err := repo.Save(first); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := repo.Save(second); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	c = load()
+	if c.version != 3 || c.sum != 4 {
+		t.Errorf("unexpected aggregate: %#v", c)
+	}
+	if second.version != c.version || second.sum != c.sum || len(second.Changes()) != 0 {
+		t.Errorf("want: %#v, got: %#v", c, second)
+	}
+}
@@ -0,0 +1,120 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var (
+	recordType = reflect.TypeOf(Record{})
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewDispatcher creates a Dispatcher that routes the records decoded by codec to
+// the handler methods of handler. Handler methods are exported methods whose
+// name starts with "On" followed by at least one character and that accept an
+// event of a type that is registered with the codec, optionally followed by the
+// Record it has been decoded from. They may return an error:
+//
+//	func (p *Projection) OnNameChanged(e NameChanged, r event.Record)
+//	func (p *Projection) OnCreated(e Created) error
+//
+// NewDispatcher panics if a handler method accepts an event of a type that is
+// not registered with the codec or if there are several handler methods for
+// the same type.
+func NewDispatcher(codec *Codec, handler interface{}) *Dispatcher {
+	d := &Dispatcher{
+		codec:    codec,
+		handlers: map[string]reflect.Value{},
+	}
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !isHandlerMethod(m) {
+			continue
+		}
+		name, _, err := codec.Marshal(json.Marshal, reflect.Zero(m.Type.In(1)).Interface())
+		if err != nil {
+			panic("event: NewDispatcher handler " + m.Name + " accepts an unregistered event type " + m.Type.In(1).String())
+		}
+		if _, dup := d.handlers[name]; dup {
+			panic("event: NewDispatcher found several handlers for " + name)
+		}
+		d.handlers[name] = v.Method(i)
+	}
+	return d
+}
+
+// Dispatcher routes records to the handler methods for their types. Only the
+// records of types that are handled are decoded.
+type Dispatcher struct {
+	codec    *Codec
+	handlers map[string]reflect.Value
+}
+
+// On dispatches a record ignoring errors. It can be used as a subscription
+// callback.
+func (d *Dispatcher) On(r Record) {
+	d.Dispatch(r)
+}
+
+// Dispatch decodes a record and calls the handler method for its type. Records
+// of types without a handler method are skipped.
+func (d *Dispatcher) Dispatch(r Record) error {
+	h, ok := d.handlers[r.Type]
+	if !ok {
+		return nil
+	}
+	e, err := d.codec.Decode(r)
+	if err != nil {
+		return err
+	}
+	in := []reflect.Value{reflect.ValueOf(e)}
+	if h.Type().NumIn() == 2 {
+		in = append(in, reflect.ValueOf(r))
+	}
+	out := h.Call(in)
+	if len(out) == 1 && !out[0].IsNil() {
+		return out[0].Interface().(error)
+	}
+	return nil
+}
+
+// Handles reports whether there is a handler method for records of a type.
+func (d *Dispatcher) Handles(recordType string) bool {
+	_, ok := d.handlers[recordType]
+	return ok
+}
+
+// isHandlerMethod reports whether a method (whose first input is its receiver)
+// has the signature of a handler method.
+func isHandlerMethod(m reflect.Method) bool {
+	if !strings.HasPrefix(m.Name, "On") || len(m.Name) == len("On") {
+		return false
+	}
+	t := m.Type
+	switch t.NumIn() {
+	case 2:
+	case 3:
+		if t.In(2) != recordType {
+			return false
+		}
+	default:
+		return false
+	}
+	if t.In(1) == recordType {
+		return false
+	}
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) != errorType {
+			return false
+		}
+	default:
+		return false
+	}
+	return true
+}
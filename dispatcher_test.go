@@ -0,0 +1,81 @@
+package event
+
+import (
+	"errors"
+	"testing"
+)
+
+type dispatcherTestRemoved struct {
+	ID string
+}
+
+type dispatcherTestHandler struct {
+	added   []repositoryTestAdded
+	removed []string
+	err     error
+}
+
+func (h *dispatcherTestHandler) OnAdded(e repositoryTestAdded, r Record) {
+	h.added = append(h.added, e)
+}
+
+func (h *dispatcherTestHandler) OnRemoved(e dispatcherTestRemoved) error {
+	h.removed = append(h.removed, e.ID)
+	return h.err
+}
+
+// On must not be treated as a handler for Records.
+func (h *dispatcherTestHandler) On(r Record) {}
+
+func TestDispatcher(t *testing.T) {
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	codec.Register("removed", dispatcherTestRemoved{})
+	codec.Register("ignored", struct{ ID string }{})
+
+	h := &dispatcherTestHandler{}
+	d := NewDispatcher(codec, h)
+	if !d.Handles("added") || !d.Handles("removed") || d.Handles("ignored") {
+		t.Errorf("unexpected handled types")
+	}
+
+	recs, err := codec.EncodeAll(Events{
+		repositoryTestAdded{ID: "a-1", Counter: "c", Amount: 2},
+		struct{ ID string }{ID: "i-1"},
+		dispatcherTestRemoved{ID: "r-1"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	recs = append(recs, Record{ID: "u-1", Type: "unknown", Data: []byte(`{}`)})
+	for _, r := range recs {
+		if err := d.Dispatch(r); err != nil {
+			t.Errorf("expected no error, but got: %v", err)
+		}
+	}
+	if len(h.added) != 1 || h.added[0].Amount != 2 {
+		t.Errorf("unexpected added: %#v", h.added)
+	}
+	if len(h.removed) != 1 || h.removed[0] != "r-1" {
+		t.Errorf("unexpected removed: %#v", h.removed)
+	}
+
+	h.err = errors.New("failed")
+	if err := d.Dispatch(recs[2]); err != h.err {
+		t.Errorf("want: %v, got: %v", h.err, err)
+	}
+	if err := d.Dispatch(Record{Type: "added", Data: []byte(`{`)}); err == nil {
+		t.Errorf("expected a decoding error")
+	}
+}
+
+func TestDispatcherUnregisteredType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	NewDispatcher(codec, &dispatcherTestHandler{})
+}
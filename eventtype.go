@@ -0,0 +1,37 @@
+package event
+
+import "strings"
+
+// EventTypePrefix is the prefix of the IDs of event type streams.
+const EventTypePrefix = "$et-"
+
+// EventTypeStream returns the ID of the event type stream of a record type. An
+// event type stream is a read-only stream that contains all records of the
+// type in the order of $all. Like in category streams, the index of a record
+// within an event type stream is its index within $all.
+func EventTypeStream(typ string) string {
+	return EventTypePrefix + typ
+}
+
+// isEventTypeStream reports whether the stream is an event type stream and
+// returns its record type.
+func isEventTypeStream(streamID string) (string, bool) {
+	if !strings.HasPrefix(streamID, EventTypePrefix) {
+		return "", false
+	}
+	typ := strings.TrimPrefix(streamID, EventTypePrefix)
+	return typ, typ != ""
+}
+
+// isStoreIndexed reports whether the records of a stream are indexed by their
+// index within $all. This holds for $all, category and event type streams.
+func isStoreIndexed(streamID string) bool {
+	if All == streamID {
+		return true
+	}
+	if _, ok := isCategoryStream(streamID); ok {
+		return true
+	}
+	_, ok := isEventTypeStream(streamID)
+	return ok
+}
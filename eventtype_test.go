@@ -0,0 +1,98 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreEventType(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		exersizeEventType(t, s)
+	})
+}
+
+func exersizeEventType(t *testing.T, s Store) {
+	appendRecord := func(streamID string, typ string) {
+		if err := s.Append(streamID, ExpectAny, Records{{Type: typ, Data: json.RawMessage(`{}`)}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	appendRecord("user-1", "user:created")
+	appendRecord("user-1", "user:name-changed")
+	appendRecord("user-2", "user:created")
+	appendRecord("order-1", "order:placed")
+	appendRecord("order-2", "order:placed")
+	appendRecord("order-3", "order:placed")
+	appendRecord("user-2", "user:name-changed")
+
+	nameChanged := EventTypeStream("user:name-changed")
+
+	if v := s.Version(nameChanged); v != 7 {
+		t.Errorf("want: %d, got: %d", 7, v)
+	}
+	if v := s.Version(EventTypeStream("user:deleted")); v != 0 {
+		t.Errorf("want: %d, got: %d", 0, v)
+	}
+
+	type origin struct {
+		Index       uint64
+		Stream      string
+		StreamIndex uint64
+	}
+	var got []origin
+	for _, r := range s.Load(nameChanged).Records() {
+		if r.StreamID != nameChanged || r.Type != "user:name-changed" {
+			t.Errorf("unexpected record: %#v", r)
+		}
+		got = append(got, origin{r.StreamIndex, r.OriginStreamID, r.OriginStreamIndex})
+	}
+	exp := []origin{{1, "user-1", 1}, {6, "user-2", 1}}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+
+	slice, err := s.LoadSlice(EventTypeStream("order:placed"), 4, 1)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(slice.Records) != 1 || slice.Records[0].StreamIndex != 4 || slice.Next != 5 || slice.IsEndOfStream {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+
+	if bs, ok := s.(BackwardStore); ok {
+		slice, err := bs.LoadSliceBackward(nameChanged, FromEnd, 1)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(slice.Records) != 1 || slice.Records[0].StreamIndex != 6 || slice.Next != 5 || slice.IsEndOfStream {
+			t.Errorf("unexpected slice: %#v", slice)
+		}
+		slice, err = bs.LoadSliceBackward(nameChanged, slice.Next, 2)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(slice.Records) != 1 || slice.Records[0].StreamIndex != 1 || !slice.IsEndOfStream {
+			t.Errorf("unexpected slice: %#v", slice)
+		}
+	}
+
+	if err := s.Append(nameChanged, ExpectAny, Records{{Type: "user:name-changed", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Errorf("expected an error since event type streams are read-only")
+	}
+
+	sub := s.SubscribeToStreamFromCurrent(nameChanged)
+	defer sub.Cancel()
+	records := sub.Records()
+	appendRecord("user-3", "user:created")
+	appendRecord("user-3", "user:name-changed")
+	select {
+	case r := <-records:
+		if r.OriginStreamID != "user-3" || r.StreamIndex != 8 {
+			t.Errorf("unexpected record: %#v", r)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a record")
+	}
+}
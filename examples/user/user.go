@@ -1,6 +1,7 @@
 package user
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -123,76 +124,60 @@ func UserCodec() *event.Codec {
 	return c
 }
 
+// AggregateID returns the id of the stream of the User. It makes a User an event.Aggregate.
+func (u *User) AggregateID() string {
+	// Each user will have its own stream within the event store.
+	return string(u.ID)
+}
+
+// AggregateVersion returns the version of the User including its pending changes. It makes a User an event.Aggregate.
+func (u *User) AggregateVersion() uint64 {
+	return u.Version
+}
+
+// UserRepository creates a repository that saves and loads Users.
+func UserRepository(store event.Store) *event.Repository {
+	// We will use the codec to un-/marshal domain events as event records and start
+	// the rehydration of each User with an empty User.
+	return event.NewRepository(store, UserCodec(), func() event.Aggregate { return NewUser() })
+}
+
 // Save can be used to dehydrate a User into an event store.
 func Save(store event.Store, user *User, metadata interface{}) error {
-	// If there are no new changes nothing needs to be stored. The unit of work is empty.
-	if len(user.Changes()) == 0 {
-		return nil
-	}
-	// Each user will have its own stream within the event store.
-	streamID := string(user.ID)
-	// Each applied event will increase the version of a user.
-	// The version of the user that was originally loaded can be calculated.
-	// The result is the expected version (length) of the event stream currently stored.
-	ex := user.Version - uint64(len(user.Changes()))
-	// We will use this codec to marshal the events as event records
-	codec := UserCodec()
-	// Encode all events in the unit of work.
-	recs, err := codec.EncodeAll(user.Changes(), event.WithMetadata(metadata))
-	if err != nil {
-		return err
-	}
-	// Append all records to the event stream.
-	err = store.Append(streamID, ex, recs)
-	if err != nil {
-		return err
-	}
-	// All events in the unit of work have been saved to the event store. We can clear all changes.
-	user.ClearChanges()
-	return nil
+	// The repository appends all changes of the unit of work to the users stream expecting the
+	// version the user was originally loaded at and clears the changes afterwards.
+	// If there are no new changes nothing needs to be stored.
+	return UserRepository(store).Save(user, event.WithMetadata(metadata))
 }
 
 // Load can be used to rehydrate a User from an event store.
 func Load(store event.Store, uID UserID) (*User, error) {
-	// We will use this codec to unmarshal event records to domain events.
-	codec := UserCodec()
-	// Create an empty user
-	user := NewUser()
-	// Each user will have its own stream within the event store.
-	streamID := string(uID)
-	// Mutate the user for each event stored in history.
-	// We are using the streaming version sice the number of events in all of histoy for this user
-	// could be quite large (and not fit into memory).
-	for rec := range store.Load(streamID) {
-		// unmarshal an event record
-		evt, err := codec.Decode(rec)
-		if err != nil {
-			return nil, err
-		}
-		// Mutate the user entity. Since these events have already been saved they are not changes
-		// that need to be tracked by the unit of work. Do not use Apply(...) here!
-		user.Mutate(evt)
-	}
-	// If the user ID is still empty then the stream was empty which would mean a user
-	// with the given uID does not exist.
-	if user.ID == "" {
-		return nil, fmt.Errorf("not found")
+	// The repository mutates an empty user for each event stored in history. It will never
+	// rehydrate a user from a partial history and returns an error if the user does not exist.
+	a, err := UserRepository(store).Load(string(uID))
+	if err != nil {
+		return nil, err
 	}
 	// The user is now fully rehydrated.
-	return user, nil
+	return a.(*User), nil
 }
 
 // NewProjection creates a new Projection. In a production system this should probably be something persistent.
 func NewProjection() *Projection {
-	return &Projection{
+	p := &Projection{
 		userNames:                  map[UserID]string{},
 		numberOfNameChangesPerUser: map[UserID]int{},
 	}
+	// The dispatcher uses the codec to unmarshal event records to domain events and routes them
+	// to the On... methods of the projection. Records of other types are skipped without being unmarshaled.
+	p.dispatcher = event.NewDispatcher(UserCodec(), p)
+	return p
 }
 
 // Projection can answer some questions about the user domain.
 type Projection struct {
 	mu                         sync.RWMutex
+	dispatcher                 *event.Dispatcher
 	userNames                  map[UserID]string
 	numberOfNameChangesPerUser map[UserID]int
 	totalNumberOfNameChanges   int
@@ -202,27 +187,22 @@ type Projection struct {
 func (p *Projection) On(rec event.Record) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	codec := UserCodec()          // We will use this codec to unmarshal event records to domain events.
-	evt, err := codec.Decode(rec) // unmarshal an event record
-	if err != nil {
-		return
-	}
-	// extract relevant information from the domain events
-	switch e := evt.(type) {
-	case Created:
-		// for our current projection we can ignore this event
-	case NameChanged:
-		// record the current name of a user
-		p.userNames[e.User] = e.Name
+	p.dispatcher.On(rec)
+}
 
-		// increment the number of name changes per user
-		n := p.numberOfNameChangesPerUser[e.User]
-		n++
-		p.numberOfNameChangesPerUser[e.User] = n
+// OnNameChanged is called by On for each NameChanged event. There is no handler for Created, since
+// our current projection can ignore this event.
+func (p *Projection) OnNameChanged(e NameChanged, rec event.Record) {
+	// record the current name of a user
+	p.userNames[e.User] = e.Name
 
-		// increment the total number of name changes
-		p.totalNumberOfNameChanges++
-	}
+	// increment the number of name changes per user
+	n := p.numberOfNameChangesPerUser[e.User]
+	n++
+	p.numberOfNameChangesPerUser[e.User] = n
+
+	// increment the total number of name changes
+	p.totalNumberOfNameChanges++
 }
 
 // UserName will retrieve a users current name.
@@ -262,6 +242,28 @@ func (p *Projection) TotalNumberOfNameChanges() int {
 
 type Metadata struct {
 	UserName    string `json:"user-name,omitempty"`
-	Causation   string `json:"causation,omitempty"`
-	Correlation string `json:"correlation,omitempty"`
+	Causation   string `json:"causation-id,omitempty"`
+	Correlation string `json:"correlation-id,omitempty"`
+}
+
+// UnmarshalJSON also reads the causation and correlation keys that metadata
+// used to be stored with before they matched event.Causation.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	type metadata Metadata
+	var v struct {
+		metadata
+		Causation   string `json:"causation,omitempty"`
+		Correlation string `json:"correlation,omitempty"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Metadata(v.metadata)
+	if m.Causation == "" {
+		m.Causation = v.Causation
+	}
+	if m.Correlation == "" {
+		m.Correlation = v.Correlation
+	}
+	return nil
 }
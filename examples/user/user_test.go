@@ -1,6 +1,7 @@
 package user
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 	"time"
@@ -9,6 +10,35 @@ import (
 )
 
 func TestEventSourcedSystem(t *testing.T) {
+	store, _ := event.NewBasicStore(":memory:")
+	defer store.Close()
+	exersizeEventSourcedSystem(t, store)
+}
+
+func TestEventSourcedSystemMemoryStore(t *testing.T) {
+	store := event.NewMemoryStore()
+	defer store.Close()
+	exersizeEventSourcedSystem(t, store)
+}
+
+func TestMetadataLegacyKeys(t *testing.T) {
+	tests := []string{
+		`{"user-name":"admin","causation-id":"cmd-1","correlation-id":"transaction:1"}`,
+		`{"user-name":"admin","causation":"cmd-1","correlation":"transaction:1"}`,
+	}
+	exp := Metadata{UserName: "admin", Causation: "cmd-1", Correlation: "transaction:1"}
+	for _, test := range tests {
+		var got Metadata
+		if err := json.Unmarshal([]byte(test), &got); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if got != exp {
+			t.Errorf("want: %#v, got: %#v", exp, got)
+		}
+	}
+}
+
+func exersizeEventSourcedSystem(t *testing.T, store event.Store) {
 	var err error
 	u := NewUser()
 	if u.ID != "" {
@@ -72,11 +102,9 @@ func TestEventSourcedSystem(t *testing.T) {
 		t.Errorf("want: %v, got: %v", 2, n)
 	}
 
-	store, _ := event.NewBasicStore(":memory:")
-	defer store.Close()
-
 	projection := NewProjection()
-	sub := store.SubscribeToStream(event.All)
+	// the projection only cares about name changes
+	sub := store.SubscribeToStream(event.EventTypeStream("user:name-changed"))
 	defer sub.Cancel()
 	sub.On(projection.On)
 
@@ -12,6 +12,8 @@ import (
 const (
 	nSkip           = "skip"
 	nLimit          = "limit"
+	nPrefix         = "prefix"
+	nAfter          = "after"
 	minPageSize     = 1
 	defaultPageSize = 50
 )
@@ -30,7 +32,9 @@ type Feeder struct {
 	PageSize uint64
 }
 
-func (f *Feeder) Page(url *url.URL) hyper.Item {
+// Page returns the page of records that is addressed by url. It returns an
+// error if the records can not be loaded.
+func (f *Feeder) Page(url *url.URL) (hyper.Item, error) {
 	limit := f.PageSize
 	version := f.Store.Version(f.StreamID)
 	if qLimit := url.Query().Get(nLimit); qLimit != "" {
@@ -77,7 +81,7 @@ func (f *Feeder) Page(url *url.URL) hyper.Item {
 
 	s, err := f.Store.LoadSlice(f.StreamID, skip, limit)
 	if err != nil {
-		return page
+		return hyper.Item{}, err
 	}
 
 	for _, r := range s.Records {
@@ -108,7 +112,7 @@ func (f *Feeder) Page(url *url.URL) hyper.Item {
 				Href: pageURL(url, s.Next, limit),
 			})
 	}
-	return page
+	return page, nil
 }
 
 func streamURL(baseURL *url.URL) string {
@@ -138,7 +142,14 @@ func numberOfPages(version uint64, limit uint64) uint64 {
 func HandleGETStream(store Store, stream string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		feeder := NewFeeder(store, stream)
-		page := feeder.Page(hyper.ExternalURL(r))
+		page, err := feeder.Page(hyper.ExternalURL(r))
+		if err != nil {
+			hyper.Write(w, http.StatusInternalServerError, Response(
+				fmt.Sprintf("could not load %s", stream),
+				err,
+			))
+			return
+		}
 		hyper.Write(w, http.StatusOK, page)
 	}
 }
@@ -7,7 +7,6 @@ require (
 	github.com/cognicraft/io v0.1.0
 	github.com/cognicraft/mux v0.1.0
 	github.com/cognicraft/pubsub v0.1.3
-	github.com/cognicraft/sqlutil v0.1.0
 	github.com/cognicraft/uri v0.1.0
 	github.com/cognicraft/uuid v0.1.0
 	github.com/mattn/go-sqlite3 v1.14.12
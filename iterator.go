@@ -0,0 +1,94 @@
+package event
+
+import (
+	"context"
+)
+
+const (
+	defaultIteratorBatchSize = uint64(50)
+)
+
+// Iterate creates a RecordIterator over the records of a stream starting at skip.
+func Iterate(store Store, streamID string, skip uint64) *RecordIterator {
+	return IterateContext(context.Background(), store, streamID, skip)
+}
+
+// IterateContext creates a RecordIterator over the records of a stream starting
+// at skip. Iteration stops with the error of the ctx once it is done.
+func IterateContext(ctx context.Context, store Store, streamID string, skip uint64) *RecordIterator {
+	var loadSlice loadSliceFunc
+	if cs, ok := store.(ContextStore); ok {
+		loadSlice = cs.LoadSliceContext
+	} else {
+		loadSlice = func(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return store.LoadSlice(streamID, skip, limit)
+		}
+	}
+	return &RecordIterator{
+		ctx:       ctx,
+		loadSlice: loadSlice,
+		batchSize: defaultIteratorBatchSize,
+		streamID:  streamID,
+		next:      skip,
+	}
+}
+
+// RecordIterator iterates over the records of a stream. In contrast to a
+// RecordStream it distinguishes between the end of a stream and a failure
+// while loading:
+//
+//	it := event.Iterate(store, streamID, 0)
+//	for it.Next() {
+//		r := it.Record()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type RecordIterator struct {
+	ctx       context.Context
+	loadSlice loadSliceFunc
+	batchSize uint64
+	streamID  string
+	next      uint64
+	buffer    Records
+	current   Record
+	end       bool
+	err       error
+}
+
+// Next advances the iterator to the next record. It returns false once the end
+// of the stream has been reached or an error occurred.
+func (it *RecordIterator) Next() bool {
+	for len(it.buffer) == 0 {
+		if it.end || it.err != nil {
+			return false
+		}
+		slice, err := it.loadSlice(it.ctx, it.streamID, it.next, it.batchSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buffer = slice.Records
+		// a slice without records that is not at the end of the stream
+		// still advances, a slice that does not advance ends the iteration.
+		it.end = slice.IsEndOfStream || slice.Next <= it.next
+		it.next = max(it.next, slice.Next)
+	}
+	it.current = it.buffer[0]
+	it.buffer = it.buffer[1:]
+	return true
+}
+
+// Record returns the current record.
+func (it *RecordIterator) Record() Record {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RecordIterator) Err() error {
+	return it.err
+}
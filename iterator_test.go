@@ -0,0 +1,79 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestRecordIterator(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	var recs Records
+	for i := 0; i < 2*int(defaultIteratorBatchSize)+1; i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	it := Iterate(s, "foo", 1)
+	n := uint64(1)
+	for it.Next() {
+		if got := it.Record().StreamIndex; got != n {
+			t.Errorf("want: %d, got: %d", n, got)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	if n != uint64(len(recs)) {
+		t.Errorf("want: %d, got: %d", len(recs), n)
+	}
+
+	it = Iterate(s, "bar", 0)
+	if it.Next() {
+		t.Errorf("expected an empty stream")
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+}
+
+func TestRecordIteratorError(t *testing.T) {
+	s := &failingStore{Store: NewMemoryStore(), failAt: defaultIteratorBatchSize}
+	defer s.Close()
+	var recs Records
+	for i := 0; i < 2*int(defaultIteratorBatchSize); i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	it := Iterate(s, "foo", 0)
+	n := uint64(0)
+	for it.Next() {
+		n++
+	}
+	if n != defaultIteratorBatchSize {
+		t.Errorf("want: %d, got: %d", defaultIteratorBatchSize, n)
+	}
+	if it.Err() == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+// failingStore fails to load slices that start at or after failAt.
+type failingStore struct {
+	Store
+	failAt uint64
+}
+
+func (s *failingStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	if skip >= s.failAt {
+		return nil, fmt.Errorf("failed to load %s at %d", streamID, skip)
+	}
+	return s.Store.LoadSlice(streamID, skip, limit)
+}
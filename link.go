@@ -0,0 +1,55 @@
+package event
+
+import "context"
+
+// LinkType is the type of link records. A link record refers to a record of
+// another stream and is resolved to that record when read, so the same record
+// can be part of several streams without duplicating its data.
+const LinkType = "$>"
+
+// Link is the data of a link record.
+type Link struct {
+	StreamID    string `json:"stream-id"`    // the id of the stream of the linked record
+	StreamIndex uint64 `json:"stream-index"` // the index of the linked record within that stream
+}
+
+// NewLink creates a link record that refers to the origin of r.
+func NewLink(r Record) (Record, error) {
+	data, err := Encode(Link{StreamID: r.OriginStreamID, StreamIndex: r.OriginStreamIndex})
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Type: LinkType, Data: data}, nil
+}
+
+// resolveLinks replaces the link records of a slice by the records they refer
+// to. The resolved records keep the position of the link within the stream
+// and point to the linked record by their origin. Links are neither resolved
+// within $all nor within event type streams and links to links as well as links
+// to records that do not exist anymore are left as they are.
+func resolveLinks(ctx context.Context, loadSlice loadSliceFunc, slice *Slice) error {
+	if _, ok := isEventTypeStream(slice.StreamID); ok || All == slice.StreamID {
+		return nil
+	}
+	for i, r := range slice.Records {
+		if LinkType != r.Type {
+			continue
+		}
+		var link Link
+		if err := Decode(r.Data, &link); err != nil {
+			continue
+		}
+		target, err := loadSlice(ctx, link.StreamID, link.StreamIndex, 1)
+		if err != nil {
+			return err
+		}
+		if len(target.Records) == 0 || target.Records[0].StreamIndex != link.StreamIndex {
+			continue
+		}
+		linked := target.Records[0]
+		linked.StreamID = r.StreamID
+		linked.StreamIndex = r.StreamIndex
+		slice.Records[i] = linked
+	}
+	return nil
+}
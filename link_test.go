@@ -0,0 +1,105 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStoreLink(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		exersizeLink(t, s)
+	})
+}
+
+func exersizeLink(t *testing.T, s Store) {
+	err := s.Append("user-1", ExpectAny, Records{
+		{ID: "1", Type: "user:created", Data: json.RawMessage(`{"name":"a"}`)},
+		{ID: "2", Type: "user:name-changed", Data: json.RawMessage(`{"name":"b"}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	err = s.Append("order-1", ExpectAny, Records{
+		{ID: "3", Type: "order:placed", Data: json.RawMessage(`{"total":1}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	users := s.Load("user-1").Records()
+	orders := s.Load("order-1").Records()
+
+	link := func(r Record) Record {
+		l, err := NewLink(r)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		return l
+	}
+	err = s.Append("tenant-x", ExpectNoStream, Records{
+		link(users[1]),
+		{ID: "4", Type: "tenant:note", Data: json.RawMessage(`{}`)},
+		link(orders[0]),
+		link(Record{OriginStreamID: "user-2", OriginStreamIndex: 0}),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	type position struct {
+		StreamID          string
+		StreamIndex       uint64
+		OriginStreamID    string
+		OriginStreamIndex uint64
+		ID                string
+		Type              string
+	}
+	positions := func(rs Records) []position {
+		var ps []position
+		for _, r := range rs {
+			ps = append(ps, position{r.StreamID, r.StreamIndex, r.OriginStreamID, r.OriginStreamIndex, r.ID, r.Type})
+		}
+		return ps
+	}
+
+	tenant := s.Load("tenant-x").Records()
+	exp := []position{
+		{"tenant-x", 0, "user-1", 1, "2", "user:name-changed"},
+		{"tenant-x", 1, "tenant-x", 1, "4", "tenant:note"},
+		{"tenant-x", 2, "order-1", 0, "3", "order:placed"},
+		{"tenant-x", 3, "tenant-x", 3, "", LinkType},
+	}
+	if got := positions(tenant); !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+	if string(tenant[0].Data) != `{"name":"b"}` {
+		t.Errorf("want: %s, got: %s", `{"name":"b"}`, tenant[0].Data)
+	}
+
+	// links are not resolved within $all
+	all := s.Load(All).Records()
+	if n := len(all); n != 7 || all[3].Type != LinkType {
+		t.Errorf("unexpected records: %v", positions(all))
+	}
+
+	// a link to a resolved link refers to the original record
+	if err := s.Append("tenant-y", ExpectNoStream, Records{link(tenant[0])}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	exp = []position{{"tenant-y", 0, "user-1", 1, "2", "user:name-changed"}}
+	if got := positions(s.Load("tenant-y").Records()); !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+
+	if bs, ok := s.(BackwardStore); ok {
+		exp := []position{
+			{"tenant-x", 3, "tenant-x", 3, "", LinkType},
+			{"tenant-x", 2, "order-1", 0, "3", "order:placed"},
+			{"tenant-x", 1, "tenant-x", 1, "4", "tenant:note"},
+			{"tenant-x", 0, "user-1", 1, "2", "user:name-changed"},
+		}
+		if got := positions(bs.LoadBackward("tenant-x").Records()); !reflect.DeepEqual(exp, got) {
+			t.Errorf("want: %v, got: %v", exp, got)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cognicraft/pubsub"
+)
+
+var (
+	_ Store               = (*MemoryStore)(nil)
+	_ ContextStore        = (*MemoryStore)(nil)
+	_ AppendResultStore   = (*MemoryStore)(nil)
+	_ TransactionalStore  = (*MemoryStore)(nil)
+	_ DeletableStore      = (*MemoryStore)(nil)
+	_ StreamMetadataStore = (*MemoryStore)(nil)
+	_ ListableStore       = (*MemoryStore)(nil)
+	_ BackwardStore       = (*MemoryStore)(nil)
+	_ TimeIndexedStore    = (*MemoryStore)(nil)
+)
+
+const (
+	defaultMSBatchSize = uint64(50)
+)
+
+// NewMemoryStore creates a Store that keeps all records in process memory.
+// It is meant for tests and ephemeral workloads; nothing is persisted.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		batchSize:   defaultMSBatchSize,
+		streams:     map[string][]uint64{},
+		deleted:     map[uint64]bool{},
+		tombstones:  map[string]bool{},
+		softDeleted: map[string]uint64{},
+		metadata:    map[string]StreamMetadata{},
+		publisher:   pubsub.NewPublisher(),
+	}
+}
+
+type MemoryStore struct {
+	batchSize   uint64
+	mu          sync.RWMutex
+	records     Records             // all records in store order
+	streams     map[string][]uint64 // store indexes of the records of each stream
+	deleted     map[uint64]bool     // store indexes of the records of deleted streams
+	tombstones  map[string]bool     // hard deleted streams
+	softDeleted map[string]uint64   // versions soft deleted streams have been deleted at
+	metadata    map[string]StreamMetadata
+	publisher   pubsub.Publisher
+}
+
+func (s *MemoryStore) Version(streamID string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return visibleVersion(s.version(streamID), s.softDeleted[streamID])
+}
+
+func (s *MemoryStore) VersionContext(ctx context.Context, streamID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.Version(streamID), nil
+}
+
+func (s *MemoryStore) Load(streamID string) RecordStream {
+	return s.LoadFrom(streamID, 0)
+}
+
+func (s *MemoryStore) LoadContext(ctx context.Context, streamID string) RecordStream {
+	return s.LoadFromContext(ctx, streamID, 0)
+}
+
+func (s *MemoryStore) LoadFrom(streamID string, skip uint64) RecordStream {
+	return s.LoadFromContext(context.Background(), streamID, skip)
+}
+
+func (s *MemoryStore) LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream {
+	return loadFrom(ctx, s.LoadSliceContext, streamID, skip, s.batchSize)
+}
+
+func (s *MemoryStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceContext(context.Background(), streamID, skip, limit)
+}
+
+func (s *MemoryStore) LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSlice(ctx, streamID, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *MemoryStore) loadSlice(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slice := Slice{
+		StreamID: streamID,
+		From:     skip,
+	}
+	version := s.version(streamID)
+	i := max(skip, s.first(streamID, version))
+	for ; i < version && uint64(len(slice.Records)) < limit; i++ {
+		if s.skipped(streamID, i) {
+			continue
+		}
+		slice.Records = append(slice.Records, s.record(streamID, i))
+	}
+	slice.IsEndOfStream = i >= version
+	if n := len(slice.Records); n > 0 {
+		slice.Next = slice.Records[n-1].StreamIndex + 1
+	}
+	return &slice, nil
+}
+
+func (s *MemoryStore) LoadBackward(streamID string) RecordStream {
+	return s.LoadBackwardContext(context.Background(), streamID)
+}
+
+func (s *MemoryStore) LoadBackwardContext(ctx context.Context, streamID string) RecordStream {
+	return loadBackward(ctx, s.LoadSliceBackwardContext, streamID, s.batchSize)
+}
+
+func (s *MemoryStore) LoadSliceBackward(streamID string, from uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceBackwardContext(context.Background(), streamID, from, limit)
+}
+
+func (s *MemoryStore) LoadSliceBackwardContext(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSliceBackward(ctx, streamID, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *MemoryStore) loadSliceBackward(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	version := s.version(streamID)
+	first := s.first(streamID, version)
+	var recs Records
+	if version > 0 && min(from, version-1) >= first {
+		for i := min(from, version-1); ; i-- {
+			if !s.skipped(streamID, i) {
+				recs = append(recs, s.record(streamID, i))
+			}
+			if uint64(len(recs)) > limit || i <= first {
+				break
+			}
+		}
+	}
+	return backwardSlice(streamID, from, limit, recs, first), nil
+}
+
+// first must be called while holding at least a read lock. It returns the index
+// of the first record of a stream that is retained by its metadata and not
+// hidden by a soft delete.
+func (s *MemoryStore) first(streamID string, version uint64) uint64 {
+	meta := s.metadata[streamID]
+	first := max(meta.first(version), s.softDeleted[streamID])
+	if meta.MaxAge <= 0 || first >= version {
+		return first
+	}
+	cutoff := meta.cutoff(time.Now().UTC())
+	for ; first < version; first++ {
+		if !s.record(streamID, first).RecordedOn.Before(cutoff) {
+			break
+		}
+	}
+	return first
+}
+
+func (s *MemoryStore) IndexAt(t time.Time) (uint64, error) {
+	return s.IndexAtContext(context.Background(), t)
+}
+
+func (s *MemoryStore) IndexAtContext(ctx context.Context, t time.Time) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, r := range s.records {
+		if !s.deleted[uint64(i)] && !r.RecordedOn.Before(t) {
+			return uint64(i), nil
+		}
+	}
+	return s.version(All), nil
+}
+
+func (s *MemoryStore) Append(streamID string, expectedVersion uint64, records Records) error {
+	return s.AppendContext(context.Background(), streamID, expectedVersion, records)
+}
+
+func (s *MemoryStore) AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	_, err := s.AppendWithResult(ctx, streamID, expectedVersion, records)
+	return err
+}
+
+func (s *MemoryStore) AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
+	if All == streamID {
+		if err := ctx.Err(); err != nil {
+			return AppendResult{}, err
+		}
+		return s.appendToStore(expectedVersion, records)
+	}
+	results, err := s.AppendMulti(ctx, StreamAppend{StreamID: streamID, ExpectedVersion: expectedVersion, Records: records})
+	if err != nil {
+		return AppendResult{}, err
+	}
+	return results[0], nil
+}
+
+func (s *MemoryStore) AppendMulti(ctx context.Context, appends ...StreamAppend) ([]AppendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkStreamAppends(appends); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	// check all appends before anything is applied.
+	duplicates := make([]bool, len(appends))
+	for i, a := range appends {
+		if s.tombstones[a.StreamID] {
+			s.mu.Unlock()
+			return nil, StreamDeletedError{Stream: a.StreamID}
+		}
+		streamVersion := s.version(a.StreamID)
+		deleted := s.softDeleted[a.StreamID]
+		if err := checkExpectedStreamVersion(a.StreamID, a.ExpectedVersion, streamVersion, deleted); err != nil {
+			dup, dErr := checkIdempotency(a.StreamID, a.ExpectedVersion, streamVersion, deleted, a.Records, func(skip uint64, limit uint64) ([]string, error) {
+				var ids []string
+				for i := skip; i < min(streamVersion, skip+limit); i++ {
+					ids = append(ids, s.record(a.StreamID, i).ID)
+				}
+				return ids, nil
+			})
+			if dup {
+				duplicates[i] = true
+				continue
+			}
+			s.mu.Unlock()
+			if dErr != nil {
+				return nil, dErr
+			}
+			return nil, err
+		}
+	}
+
+	results := make([]AppendResult, len(appends))
+	for i, a := range appends {
+		streamID := a.StreamID
+		streamVersion := s.version(streamID)
+		res := AppendResult{StreamID: streamID, Version: streamVersion}
+		if !duplicates[i] {
+			for _, e := range a.Records {
+				if e.RecordedOn.IsZero() {
+					e.RecordedOn = time.Now().UTC()
+				}
+				e.StreamID = streamID
+				e.StreamIndex = streamVersion
+				e.OriginStreamID = streamID
+				e.OriginStreamIndex = streamVersion
+				streamVersion++
+				storeIndex := uint64(len(s.records))
+				s.streams[streamID] = append(s.streams[streamID], storeIndex)
+				s.records = append(s.records, e)
+				res.add(e.StreamIndex, storeIndex, e.RecordedOn)
+			}
+		}
+		results[i] = res
+	}
+	s.mu.Unlock()
+
+	for _, res := range results {
+		if res.Count() > 0 {
+			s.publisher.Publish(topicAppend, res.StreamID)
+		}
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) appendToStore(expectedVersion uint64, records Records) (AppendResult, error) {
+	s.mu.Lock()
+	storeVersion := uint64(len(s.records))
+	if err := checkExpectedVersion(All, expectedVersion, storeVersion); err != nil {
+		s.mu.Unlock()
+		return AppendResult{}, err
+	}
+	for i, e := range records {
+		if e.StreamIndex != storeVersion+uint64(i) {
+			s.mu.Unlock()
+			return AppendResult{}, fmt.Errorf("record %d has store index %d but expected %d", i, e.StreamIndex, storeVersion+uint64(i))
+		}
+	}
+
+	res := AppendResult{StreamID: All, Version: storeVersion}
+	updatedStreams := map[string]bool{}
+	for _, e := range records {
+		storeIndex := e.StreamIndex
+		e.StreamID = e.OriginStreamID
+		e.StreamIndex = e.OriginStreamIndex
+		s.streams[e.StreamID] = append(s.streams[e.StreamID], storeIndex)
+		s.records = append(s.records, e)
+		updatedStreams[e.StreamID] = true
+		res.add(storeIndex, storeIndex, e.RecordedOn)
+	}
+	s.mu.Unlock()
+
+	for streamID := range updatedStreams {
+		s.publisher.Publish(topicAppend, streamID)
+	}
+	return res, nil
+}
+
+func (s *MemoryStore) DeleteStream(streamID string, expectedVersion uint64, hard bool) error {
+	return s.DeleteStreamContext(context.Background(), streamID, expectedVersion, hard)
+}
+
+func (s *MemoryStore) DeleteStreamContext(ctx context.Context, streamID string, expectedVersion uint64, hard bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if All == streamID {
+		return fmt.Errorf("%s can not be deleted", All)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tombstones[streamID] {
+		return StreamDeletedError{Stream: streamID}
+	}
+	version := s.version(streamID)
+	if err := checkExpectedStreamVersion(streamID, expectedVersion, version, s.softDeleted[streamID]); err != nil {
+		return err
+	}
+	if !hard {
+		s.softDeleted[streamID] = version
+		return nil
+	}
+	for _, storeIndex := range s.streams[streamID] {
+		s.records[storeIndex] = Record{}
+		s.deleted[storeIndex] = true
+	}
+	delete(s.streams, streamID)
+	delete(s.metadata, streamID)
+	delete(s.softDeleted, streamID)
+	s.tombstones[streamID] = true
+	return nil
+}
+
+func (s *MemoryStore) StreamMetadata(streamID string) (StreamMetadata, error) {
+	return s.StreamMetadataContext(context.Background(), streamID)
+}
+
+func (s *MemoryStore) StreamMetadataContext(ctx context.Context, streamID string) (StreamMetadata, error) {
+	if err := ctx.Err(); err != nil {
+		return StreamMetadata{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metadata[streamID], nil
+}
+
+func (s *MemoryStore) SetStreamMetadata(streamID string, meta StreamMetadata) error {
+	return s.SetStreamMetadataContext(context.Background(), streamID, meta)
+}
+
+func (s *MemoryStore) SetStreamMetadataContext(ctx context.Context, streamID string, meta StreamMetadata) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if All == streamID {
+		return fmt.Errorf("metadata can not be set for %s", All)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if meta.IsZero() {
+		delete(s.metadata, streamID)
+	} else {
+		s.metadata[streamID] = meta
+	}
+	return nil
+}
+
+func (s *MemoryStore) Streams(prefix string, after string, limit int) ([]StreamInfo, error) {
+	return s.StreamsContext(context.Background(), prefix, after, limit)
+}
+
+func (s *MemoryStore) StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id := range s.streams {
+		// soft deleted streams are hidden until they are recreated.
+		if s.version(id) > s.softDeleted[id] {
+			ids = append(ids, id)
+		}
+	}
+	var infos []StreamInfo
+	for _, id := range selectStreamIDs(ids, prefix, after, limit) {
+		version := s.version(id)
+		infos = append(infos, StreamInfo{
+			StreamID:  id,
+			Version:   version,
+			LastWrite: s.record(id, version-1).RecordedOn,
+		})
+	}
+	return infos, nil
+}
+
+func (s *MemoryStore) SubscribeToStream(streamID string) Subscription {
+	return s.SubscribeToStreamContext(context.Background(), streamID)
+}
+
+func (s *MemoryStore) SubscribeToStreamContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, 0)
+}
+
+func (s *MemoryStore) SubscribeToStreamFrom(streamID string, version uint64) Subscription {
+	return s.SubscribeToStreamFromContext(context.Background(), streamID, version)
+}
+
+func (s *MemoryStore) SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription {
+	return &subscription{
+		ctx:       ctx,
+		loadSlice: s.LoadSliceContext,
+		batchSize: s.batchSize,
+		subscribe: s.publisher.Subscribe,
+		streamID:  streamID,
+		from:      version,
+	}
+}
+
+func (s *MemoryStore) SubscribeToStreamFromCurrent(streamID string) Subscription {
+	return s.SubscribeToStreamFromCurrentContext(context.Background(), streamID)
+}
+
+func (s *MemoryStore) SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, s.Version(streamID))
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// version must be called while holding at least a read lock.
+func (s *MemoryStore) version(streamID string) uint64 {
+	if All == streamID {
+		return uint64(len(s.records))
+	}
+	if isStoreIndexed(streamID) {
+		for i := len(s.records) - 1; i >= 0; i-- {
+			if !s.skipped(streamID, uint64(i)) {
+				return uint64(i) + 1
+			}
+		}
+		return 0
+	}
+	return uint64(len(s.streams[streamID]))
+}
+
+// record must be called while holding at least a read lock.
+func (s *MemoryStore) record(streamID string, index uint64) Record {
+	if isStoreIndexed(streamID) {
+		r := s.records[index]
+		r.StreamID = streamID
+		r.StreamIndex = index
+		return r
+	}
+	return s.records[s.streams[streamID][index]]
+}
+
+// skipped must be called while holding at least a read lock. It reports whether
+// a position of $all, of a category or of an event type stream does not hold a
+// record of it.
+func (s *MemoryStore) skipped(streamID string, index uint64) bool {
+	if All == streamID {
+		return s.deleted[index]
+	}
+	if _, ok := isCategoryStream(streamID); ok {
+		return s.deleted[index] || !inCategoryStream(streamID, s.records[index].StreamID)
+	}
+	if typ, ok := isEventTypeStream(streamID); ok {
+		return s.deleted[index] || s.records[index].Type != typ
+	}
+	return false
+}
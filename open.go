@@ -0,0 +1,114 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OpenFunc opens a Store for a data source name. The data source name that is
+// passed to an OpenFunc no longer contains the scheme prefix (e.g. "sqlite:" or
+// "chunked://").
+type OpenFunc func(dataSourceName string) (Store, error)
+
+const (
+	// DefaultScheme is used for data source names that do not specify a scheme.
+	DefaultScheme = "sqlite"
+)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]OpenFunc{}
+)
+
+func init() {
+	Register("sqlite", func(dsn string) (Store, error) {
+		return NewBasicStore(dsn)
+	})
+	Register("sqlite3", func(dsn string) (Store, error) {
+		return NewBasicStore(dsn)
+	})
+	// sqlite URI filenames (e.g. "file:events.db?cache=shared") keep their
+	// scheme, since it is part of the data source name sqlite expects.
+	Register("file", func(dsn string) (Store, error) {
+		return NewBasicStore("file:" + dsn)
+	})
+	Register("chunked", func(dsn string) (Store, error) {
+		return NewChunkedStore(dsn)
+	})
+	Register("segment", func(dsn string) (Store, error) {
+		return NewSegmentStore(dsn)
+	})
+	Register("memory", func(dsn string) (Store, error) {
+		return NewMemoryStore(), nil
+	})
+}
+
+// Register makes a Store backend available by the provided scheme. If Register
+// is called twice with the same scheme or if open is nil, it panics.
+func Register(scheme string, open OpenFunc) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	if open == nil {
+		panic("event: Register open func is nil")
+	}
+	scheme = strings.ToLower(scheme)
+	if _, dup := openers[scheme]; dup {
+		panic("event: Register called twice for scheme " + scheme)
+	}
+	openers[scheme] = open
+}
+
+// Schemes returns a sorted list of the schemes of all registered backends.
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	var schemes []string
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens a Store by dispatching on the scheme of the data source name, e.g.:
+//
+//	sqlite:data/events.db
+//	file:data/events.db?cache=shared
+//	chunked:data/events?chunk-size=100000
+//	segment:data/events?segment-size=100000
+//	memory:
+//
+// A data source name without a scheme is opened with the DefaultScheme.
+func Open(dataSourceName string) (Store, error) {
+	scheme, dsn := splitScheme(dataSourceName)
+	openersMu.RLock()
+	open, ok := openers[scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store scheme %q (forgotten import?)", scheme)
+	}
+	return open(dsn)
+}
+
+func splitScheme(dsn string) (string, string) {
+	i := strings.Index(dsn, ":")
+	// a single letter is most likely a windows drive and not a scheme.
+	if i < 2 || !isScheme(dsn[:i]) {
+		return DefaultScheme, dsn
+	}
+	return strings.ToLower(dsn[:i]), strings.TrimPrefix(dsn[i+1:], "//")
+}
+
+func isScheme(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package event
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSplitScheme(t *testing.T) {
+	tests := []struct {
+		in     string
+		scheme string
+		dsn    string
+	}{
+		{in: "data/events.db", scheme: "sqlite", dsn: "data/events.db"},
+		{in: ":memory:", scheme: "sqlite", dsn: ":memory:"},
+		{in: "sqlite:data/events.db", scheme: "sqlite", dsn: "data/events.db"},
+		{in: "sqlite://data/events.db?_synchronous=FULL", scheme: "sqlite", dsn: "data/events.db?_synchronous=FULL"},
+		{in: "chunked:/var/lib/events?chunk-size=2", scheme: "chunked", dsn: "/var/lib/events?chunk-size=2"},
+		{in: "Memory:", scheme: "memory", dsn: ""},
+		{in: "file:events.db?cache=shared", scheme: "file", dsn: "events.db?cache=shared"},
+		{in: `C:\data\events.db`, scheme: "sqlite", dsn: `C:\data\events.db`},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("test-%d", i), func(t *testing.T) {
+			scheme, dsn := splitScheme(test.in)
+			if scheme != test.scheme {
+				t.Errorf("want: %s, got: %s", test.scheme, scheme)
+			}
+			if dsn != test.dsn {
+				t.Errorf("want: %s, got: %s", test.dsn, dsn)
+			}
+		})
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{in: ":memory:", out: ":memory:?_foreign_keys=on&_journal_mode=WAL&_locking_mode=NORMAL&_synchronous=OFF"},
+		{in: "events.db?_synchronous=FULL&cache=shared", out: "events.db?_foreign_keys=on&_journal_mode=WAL&_locking_mode=NORMAL&_synchronous=FULL&cache=shared"},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("test-%d", i), func(t *testing.T) {
+			if got := setOptions(test.in); got != test.out {
+				t.Errorf("want: %s, got: %s", test.out, got)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Open("memory:")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if _, ok := s.(*MemoryStore); !ok {
+		t.Errorf("expected a %T, but got: %T", &MemoryStore{}, s)
+	}
+
+	s, err = Open("file::memory:?cache=shared")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if _, ok := s.(*BasicStore); !ok {
+		t.Errorf("expected a %T, but got: %T", &BasicStore{}, s)
+	}
+
+	if _, err := Open("unknown:foo"); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	registerTestOpen.Do(func() {
+		Register("test-open", func(dsn string) (Store, error) {
+			testOpenDSN = dsn
+			return NewMemoryStore(), nil
+		})
+	})
+	if _, err := Open("test-open://foo?bar=baz"); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if testOpenDSN != "foo?bar=baz" {
+		t.Errorf("want: %s, got: %s", "foo?bar=baz", testOpenDSN)
+	}
+}
+
+var (
+	registerTestOpen sync.Once
+	testOpenDSN      string
+)
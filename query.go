@@ -0,0 +1,117 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+)
+
+// QueryableStore is a Store that can find the records of $all by values within
+// their data or metadata. Query slices are slices of $all that only contain the
+// records that match all conditions, so their Next can be used as skip to
+// request the next page.
+type QueryableStore interface {
+	Store
+	Query(skip uint64, limit uint64, conditions ...Condition) (*Slice, error)
+	QueryContext(ctx context.Context, skip uint64, limit uint64, conditions ...Condition) (*Slice, error)
+	// IndexPath creates an index that speeds up queries for a path of a field.
+	IndexPath(field Field, path string) error
+}
+
+// Field is a JSON field of a record that can be queried.
+type Field string
+
+const (
+	DataField     Field = "data"
+	MetadataField Field = "metadata"
+)
+
+// Condition is satisfied by records whose field holds value at the JSON path,
+// e.g. "$.correlation" or "$.items[0].sku".
+type Condition struct {
+	Field Field
+	Path  string
+	Value interface{} // a string, number or bool
+}
+
+// DataEquals is satisfied by records whose data holds value at path.
+func DataEquals(path string, value interface{}) Condition {
+	return Condition{Field: DataField, Path: path, Value: value}
+}
+
+// MetadataEquals is satisfied by records whose metadata holds value at path.
+func MetadataEquals(path string, value interface{}) Condition {
+	return Condition{Field: MetadataField, Path: path, Value: value}
+}
+
+var jsonPathPattern = regexp.MustCompile(`^\$(\.[A-Za-z0-9_-]+|\[[0-9]+\])*$`)
+
+// jsonExpression returns the sqlite expression that extracts the value at path
+// from field. Records whose field does not hold valid JSON have no values. The
+// path is part of the expression instead of a parameter, so that indexes on the
+// expression can be used.
+func jsonExpression(field Field, path string) (string, error) {
+	if DataField != field && MetadataField != field {
+		return "", fmt.Errorf("unknown field %q", field)
+	}
+	if !jsonPathPattern.MatchString(path) {
+		return "", fmt.Errorf("invalid JSON path %q", path)
+	}
+	value := fmt.Sprintf("CAST(%s AS TEXT)", field)
+	return fmt.Sprintf("(CASE WHEN json_valid(%s) THEN json_extract(%s, '%s') END)", value, value, path), nil
+}
+
+// jsonIndex returns the statement that creates an index on the value at path
+// within field.
+func jsonIndex(field Field, path string) (string, error) {
+	expr, err := jsonExpression(field, path)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_events_%s_%x ON events %s;", field, h.Sum64(), expr), nil
+}
+
+// queryStatement returns the statement that selects the matching records of
+// $all starting at a store index and its arguments.
+func queryStatement(skip uint64, limit uint64, conditions []Condition) (string, []interface{}, error) {
+	query := `
+		SELECT '$all', storeIndex, streamID, streamIndex, recordedOn, id, type, data, metadata
+		FROM   events
+		WHERE  storeIndex >= ?`
+	args := []interface{}{int64(skip)}
+	for _, c := range conditions {
+		expr, err := jsonExpression(c.Field, c.Path)
+		if err != nil {
+			return "", nil, err
+		}
+		query += `
+		       AND ` + expr + ` = ?`
+		args = append(args, c.Value)
+	}
+	query += `
+		ORDER  BY storeIndex
+		LIMIT  ?;`
+	args = append(args, int64(limit))
+	return query, args, nil
+}
+
+// querySlice completes a query slice of records that have been loaded with a
+// limit of limit+1.
+func querySlice(skip uint64, limit uint64, records Records) *Slice {
+	slice := &Slice{
+		StreamID: All,
+		From:     skip,
+		Records:  records,
+	}
+	slice.IsEndOfStream = uint64(len(records)) <= limit
+	if !slice.IsEndOfStream {
+		slice.Records = records[:limit]
+	}
+	if n := len(slice.Records); n > 0 {
+		slice.Next = slice.Records[n-1].StreamIndex + 1
+	}
+	return slice
+}
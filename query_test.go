@@ -0,0 +1,141 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStoreQuery(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		qs, ok := s.(QueryableStore)
+		if !ok {
+			t.Skip("records can not be queried")
+		}
+		exersizeQuery(t, qs)
+	})
+}
+
+func TestBasicStoreQueryPlan(t *testing.T) {
+	s, err := NewBasicStore(":memory:")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if err := s.IndexPath(MetadataField, "$.correlation"); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	query, args, err := queryStatement(0, 10, []Condition{MetadataEquals("$.correlation", "c1")})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	rows, err := s.db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if p := strings.Join(plan, "\n"); !strings.Contains(p, "idx_events_metadata_") {
+		t.Errorf("expected the path index to be used, but got: %s", p)
+	}
+}
+
+func TestQueryInvalidPath(t *testing.T) {
+	tests := []Condition{
+		MetadataEquals("correlation", "c1"),
+		MetadataEquals("$.correlation') OR 1=1 --", "c1"),
+		{Field: "type", Path: "$.name", Value: "x"},
+	}
+	for _, c := range tests {
+		if _, _, err := queryStatement(0, 1, []Condition{c}); err == nil {
+			t.Errorf("expected an error for: %#v", c)
+		}
+	}
+}
+
+func exersizeQuery(t *testing.T, s QueryableStore) {
+	if err := s.IndexPath(MetadataField, "$.correlation"); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	records := []struct {
+		streamID string
+		data     string
+		metadata string
+	}{
+		{"user-1", `{"name":"a","active":true}`, `{"correlation":"c1","user":"admin"}`},
+		{"user-2", `{"name":"b","active":false}`, `{"correlation":"c2","user":"admin"}`},
+		{"order-1", `{"items":[{"sku":"x"}],"total":3}`, `{"correlation":"c1","user":"bob"}`},
+		{"order-2", `{"items":[{"sku":"y"}],"total":4}`, ``},
+		{"order-3", `not json`, `{"correlation":"c1","user":"admin"}`},
+	}
+	for _, r := range records {
+		rec := Record{Type: "test", Data: json.RawMessage(r.data)}
+		if r.metadata != "" {
+			rec.Metadata = json.RawMessage(r.metadata)
+		}
+		if err := s.Append(r.streamID, ExpectAny, Records{rec}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+
+	indexes := func(slice *Slice) []uint64 {
+		var is []uint64
+		for _, r := range slice.Records {
+			if r.StreamID != All {
+				t.Errorf("want: %s, got: %s", All, r.StreamID)
+			}
+			is = append(is, r.StreamIndex)
+		}
+		return is
+	}
+
+	tests := []struct {
+		conditions []Condition
+		exp        []uint64
+	}{
+		{[]Condition{MetadataEquals("$.correlation", "c1")}, []uint64{0, 2, 4}},
+		{[]Condition{MetadataEquals("$.correlation", "c1"), MetadataEquals("$.user", "admin")}, []uint64{0, 4}},
+		{[]Condition{DataEquals("$.active", true)}, []uint64{0}},
+		{[]Condition{DataEquals("$.total", 4)}, []uint64{3}},
+		{[]Condition{DataEquals("$.items[0].sku", "x")}, []uint64{2}},
+		{[]Condition{MetadataEquals("$.correlation", "c3")}, nil},
+	}
+	for _, test := range tests {
+		slice, err := s.Query(0, 10, test.conditions...)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if got := indexes(slice); !reflect.DeepEqual(test.exp, got) || !slice.IsEndOfStream {
+			t.Errorf("%v want: %v, got: %v", test.conditions, test.exp, got)
+		}
+	}
+
+	slice, err := s.Query(0, 2, MetadataEquals("$.correlation", "c1"))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got := indexes(slice); !reflect.DeepEqual([]uint64{0, 2}, got) || slice.IsEndOfStream || slice.Next != 3 {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+	slice, err = s.Query(slice.Next, 2, MetadataEquals("$.correlation", "c1"))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got := indexes(slice); !reflect.DeepEqual([]uint64{4}, got) || !slice.IsEndOfStream {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+
+	if _, err := s.Query(0, 10, MetadataEquals("correlation", "c1")); err == nil {
+		t.Errorf("expected an error for an invalid path")
+	}
+}
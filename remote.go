@@ -0,0 +1,347 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cognicraft/hyper"
+)
+
+var (
+	_ Store             = (*RemoteStore)(nil)
+	_ ContextStore      = (*RemoteStore)(nil)
+	_ AppendResultStore = (*RemoteStore)(nil)
+	_ ListableStore     = (*RemoteStore)(nil)
+)
+
+const (
+	defaultRSBatchSize = uint64(50)
+)
+
+func init() {
+	Register("http", func(dsn string) (Store, error) {
+		return NewRemoteStore("http://" + dsn)
+	})
+	Register("https", func(dsn string) (Store, error) {
+		return NewRemoteStore("https://" + dsn)
+	})
+}
+
+type RemoteStoreOption func(*RemoteStore) error
+
+// RemoteClient sets the http.Client that is used to talk to the Server. The
+// default client verifies the certificates of the Server, a client with an
+// insecure tls.Config has to be provided explicitly to skip the verification.
+func RemoteClient(client *http.Client) RemoteStoreOption {
+	return func(s *RemoteStore) error {
+		s.client = client
+		return nil
+	}
+}
+
+// RemoteBatchSize sets the number of records that are requested per page.
+func RemoteBatchSize(size uint64) RemoteStoreOption {
+	return func(s *RemoteStore) error {
+		if size == 0 {
+			return fmt.Errorf("batch size must be greater than 0")
+		}
+		s.batchSize = size
+		return nil
+	}
+}
+
+// NewRemoteStore creates a Store that talks to a Server. The baseURL is the
+// root of the Server, e.g. http://127.0.0.1:4711/
+func NewRemoteStore(baseURL string, opts ...RemoteStoreOption) (*RemoteStore, error) {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &RemoteStore{
+		baseURL:   u,
+		batchSize: defaultRSBatchSize,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.client == nil {
+		s.client = &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		}
+	}
+	return s, nil
+}
+
+type RemoteStore struct {
+	baseURL   *url.URL
+	batchSize uint64
+	client    *http.Client
+}
+
+func (s *RemoteStore) Version(streamID string) uint64 {
+	version, _ := s.VersionContext(context.Background(), streamID)
+	return version
+}
+
+func (s *RemoteStore) VersionContext(ctx context.Context, streamID string) (uint64, error) {
+	page, err := s.get(ctx, s.streamURL(streamID))
+	if err != nil {
+		return 0, err
+	}
+	return currentVersion(page), nil
+}
+
+func (s *RemoteStore) Load(streamID string) RecordStream {
+	return s.LoadFrom(streamID, 0)
+}
+
+func (s *RemoteStore) LoadContext(ctx context.Context, streamID string) RecordStream {
+	return s.LoadFromContext(ctx, streamID, 0)
+}
+
+func (s *RemoteStore) LoadFrom(streamID string, skip uint64) RecordStream {
+	return s.LoadFromContext(context.Background(), streamID, skip)
+}
+
+func (s *RemoteStore) LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream {
+	return loadFrom(ctx, s.LoadSliceContext, streamID, skip, s.batchSize)
+}
+
+func (s *RemoteStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceContext(context.Background(), streamID, skip, limit)
+}
+
+func (s *RemoteStore) LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	// request one additional record to find out if the end of the stream has been reached.
+	u := fmt.Sprintf("%s?%s=%d&%s=%d", s.streamURL(streamID), nSkip, skip, nLimit, limit+1)
+	page, err := s.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	slice := Slice{
+		StreamID: streamID,
+		From:     skip,
+	}
+	// pages list the most recent record first
+	for i := len(page.Items) - 1; i >= 0; i-- {
+		rItem := page.Items[i]
+		if rItem.Type != "event-record" {
+			continue
+		}
+		r := Record{}
+		if err := rItem.DecodeData(&r); err != nil {
+			return nil, err
+		}
+		slice.Records = append(slice.Records, r)
+	}
+	slice.IsEndOfStream = (uint64(len(slice.Records)) <= limit)
+	if !slice.IsEndOfStream {
+		slice.Records = slice.Records[:limit]
+	}
+	if n := len(slice.Records); n > 0 {
+		slice.Next = slice.Records[n-1].StreamIndex + 1
+	}
+	return &slice, nil
+}
+
+func (s *RemoteStore) Append(streamID string, expectedVersion uint64, records Records) error {
+	return s.AppendContext(context.Background(), streamID, expectedVersion, records)
+}
+
+func (s *RemoteStore) AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	_, err := s.append(ctx, streamID, expectedVersion, records)
+	return err
+}
+
+// AppendWithResult appends records to a stream. It fails if the Server is
+// backed by a store that does not report append results.
+func (s *RemoteStore) AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
+	res, err := s.append(ctx, streamID, expectedVersion, records)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	if len(res.Data) == 0 {
+		return AppendResult{}, fmt.Errorf("the server did not report an append result for %s", streamID)
+	}
+	var result AppendResult
+	if err := res.DecodeData(&result); err != nil {
+		return AppendResult{}, err
+	}
+	return result, nil
+}
+
+func (s *RemoteStore) append(ctx context.Context, streamID string, expectedVersion uint64, records Records) (hyper.Item, error) {
+	if records == nil {
+		records = Records{}
+	}
+	args := map[string]interface{}{
+		"@action": "append",
+		"events":  records,
+	}
+	switch expectedVersion {
+	case ExpectAny:
+	case ExpectNoStream, ExpectStreamExists:
+		args["expected-version"] = FormatExpectedVersion(expectedVersion)
+	default:
+		args["expected-version"] = expectedVersion
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return hyper.Item{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.streamURL(streamID), bytes.NewReader(body))
+	if err != nil {
+		return hyper.Item{}, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(hyper.HeaderAccept, hyper.ContentTypeHyperItem)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return hyper.Item{}, err
+	}
+	defer resp.Body.Close()
+	res := hyper.Item{}
+	if resp.StatusCode == http.StatusOK {
+		err := json.NewDecoder(resp.Body).Decode(&res)
+		return res, err
+	}
+	json.NewDecoder(resp.Body).Decode(&res)
+	for _, e := range res.Errors {
+		switch e.Code {
+		case codeOptimisticConcurrency:
+			ocErr := OptimisticConcurrencyError{Stream: streamID, Expected: expectedVersion}
+			res.DecodeData(&ocErr)
+			return hyper.Item{}, ocErr
+		case codeIdempotency:
+			iErr := IdempotencyError{Stream: streamID, Expected: expectedVersion, Count: len(records)}
+			res.DecodeData(&iErr)
+			return hyper.Item{}, iErr
+		}
+	}
+	if len(res.Errors) > 0 {
+		return hyper.Item{}, fmt.Errorf("could not append to %s: %s", streamID, res.Errors[0].Message)
+	}
+	return hyper.Item{}, fmt.Errorf("could not append to %s: bad status: %s", streamID, resp.Status)
+}
+
+// Streams lists the streams of the Server. No streams are listed if the Server
+// is backed by a store that can not enumerate its streams.
+func (s *RemoteStore) Streams(prefix string, after string, limit int) ([]StreamInfo, error) {
+	return s.StreamsContext(context.Background(), prefix, after, limit)
+}
+
+func (s *RemoteStore) StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error) {
+	var infos []StreamInfo
+	for limit <= 0 || len(infos) < limit {
+		pageSize := int(s.batchSize)
+		if limit > 0 {
+			pageSize = limit - len(infos)
+		}
+		u := s.baseURL.String() + "streams/?" + url.Values{
+			nPrefix: {prefix},
+			nAfter:  {after},
+			nLimit:  {strconv.Itoa(pageSize)},
+		}.Encode()
+		page, err := s.get(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		for _, item := range page.Items {
+			if item.Type != "stream" {
+				continue
+			}
+			var info StreamInfo
+			if err := item.DecodeData(&info); err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+			after = info.StreamID
+			n++
+		}
+		if n < pageSize {
+			break
+		}
+	}
+	return infos, nil
+}
+
+func (s *RemoteStore) SubscribeToStream(streamID string) Subscription {
+	return s.SubscribeToStreamContext(context.Background(), streamID)
+}
+
+func (s *RemoteStore) SubscribeToStreamContext(ctx context.Context, streamID string) Subscription {
+	return s.subscribe(streamID, 0, UseContext(ctx), Follow())
+}
+
+func (s *RemoteStore) SubscribeToStreamFrom(streamID string, version uint64) Subscription {
+	return s.SubscribeToStreamFromContext(context.Background(), streamID, version)
+}
+
+func (s *RemoteStore) SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription {
+	return s.subscribe(streamID, version, UseContext(ctx), Follow(), From(version))
+}
+
+func (s *RemoteStore) SubscribeToStreamFromCurrent(streamID string) Subscription {
+	return s.SubscribeToStreamFromCurrentContext(context.Background(), streamID)
+}
+
+func (s *RemoteStore) SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription {
+	return s.subscribe(streamID, 0, UseContext(ctx), Follow(), FromCurrent())
+}
+
+func (s *RemoteStore) Close() error {
+	type connectionCloser interface {
+		CloseIdleConnections()
+	}
+	if t, ok := s.client.Transport.(connectionCloser); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (s *RemoteStore) subscribe(streamID string, from uint64, opts ...StreamerOption) Subscription {
+	u := s.streamURL(streamID)
+	// none of the options that are used by the RemoteStore can fail.
+	streamer, _ := NewStreamer(u, append(opts, UseClient(s.client))...)
+	return &urlSubscription{
+		url:      u,
+		from:     from,
+		streamer: streamer,
+	}
+}
+
+func (s *RemoteStore) streamURL(streamID string) string {
+	return s.baseURL.String() + "streams/" + url.PathEscape(streamID)
+}
+
+func (s *RemoteStore) get(ctx context.Context, u string) (hyper.Item, error) {
+	resp, err := s.client.Do(request(u).WithContext(ctx))
+	if err != nil {
+		return hyper.Item{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return hyper.Item{}, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	page := hyper.Item{}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
@@ -0,0 +1,154 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRemoteStore(t *testing.T) {
+	server, err := NewServer(NewMemoryStore())
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer server.Close()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	s, err := NewRemoteStore(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	exersizeStore(t, s)
+
+	err = s.Append("foo", 1, Records{
+		{ID: "5", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	ocErr, ok := err.(OptimisticConcurrencyError)
+	if !ok {
+		t.Fatalf("expected an optimistic concurrency error, but got: %v", err)
+	}
+	if ocErr.Actual != 4 {
+		t.Errorf("want: %d, got: %d", 4, ocErr.Actual)
+	}
+}
+
+func TestRemoteStoreStreams(t *testing.T) {
+	server, err := NewServer(NewMemoryStore())
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer server.Close()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	s, err := NewRemoteStore(ts.URL, RemoteBatchSize(1))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	exersizeStreams(t, s)
+}
+
+func TestRemoteStoreVerifiesCertificates(t *testing.T) {
+	server, err := NewServer(NewMemoryStore())
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer server.Close()
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	s, err := NewRemoteStore(ts.URL)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.VersionContext(context.Background(), "foo"); err == nil {
+		t.Errorf("expected an error for the self-signed certificate")
+	}
+
+	s, err = NewRemoteStore(ts.URL, RemoteClient(ts.Client()))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.VersionContext(context.Background(), "foo"); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+}
+
+func TestRemoteStoreLoadError(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore(), failAt: 2}
+	server, err := NewServer(store)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer server.Close()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	var recs Records
+	for i := 0; i < 4; i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := store.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	s, err := NewRemoteStore(ts.URL, RemoteBatchSize(1))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.LoadSlice("foo", 2, 1); err == nil {
+		t.Errorf("expected an error")
+	}
+	it := Iterate(s, "foo", 2)
+	if it.Next() {
+		t.Errorf("expected no records")
+	}
+	if it.Err() == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestServerRejectsMalformedExpectedVersion(t *testing.T) {
+	store := NewMemoryStore()
+	server, err := NewServer(store)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer server.Close()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for _, ev := range []string{`"latest"`, `-1`, `1.5`, `true`, `""`} {
+		body := `{"@action":"append","events":[{"type":"test","data":{}}],"expected-version":` + ev + `}`
+		resp, err := http.Post(ts.URL+"/streams/foo", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, but got: %d", ev, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+	if v := store.Version("foo"); v != 0 {
+		t.Errorf("expected version 0, but got: %d", v)
+	}
+
+	body := `{"@action":"append","events":[{"type":"test","data":{}}],"expected-version":null}`
+	resp, err := http.Post(ts.URL+"/streams/foo", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	resp.Body.Close()
+	if v := store.Version("foo"); v != 1 {
+		t.Errorf("expected version 1, but got: %d", v)
+	}
+}
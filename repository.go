@@ -0,0 +1,201 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Aggregate is an event sourced entity that can be saved and loaded by a
+// Repository. Mutate must advance the version of the aggregate by one for each
+// event, so that the version includes the recorded changes. Changes and
+// ClearChanges are usually provided by an embedded *ChangeRecorder.
+type Aggregate interface {
+	AggregateID() string      // the id of the stream of the aggregate
+	AggregateVersion() uint64 // the version of the aggregate including its changes
+	Mutate(e Event)
+	Changes() Events
+	ClearChanges()
+}
+
+const (
+	defaultRebaseAttempts = 3
+)
+
+// RepositoryOption configures a Repository.
+type RepositoryOption func(*Repository)
+
+// AutoRebase lets a Repository rebase the changes of an aggregate onto the
+// events that have been saved concurrently, unless they conflict. The
+// aggregate is rehydrated from its stream including the intervening events and
+// the changes are applied and appended after them, so that its state follows
+// the order of the store. The aggregate must be a pointer of the type that the
+// create function of the Repository returns.
+func AutoRebase(fn ConflictFunc) RepositoryOption {
+	return func(r *Repository) {
+		r.rebase = fn
+	}
+}
+
+// NewRepository creates a Repository that stores aggregates in store. The codec
+// encodes and decodes the events of the aggregates and create creates an empty
+// aggregate that the history is applied to.
+func NewRepository(store Store, codec *Codec, create func() Aggregate, opts ...RepositoryOption) *Repository {
+	r := &Repository{
+		store:  store,
+		codec:  codec,
+		create: create,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Repository saves and loads aggregates. Each aggregate is stored in its own
+// stream.
+type Repository struct {
+	store  Store
+	codec  *Codec
+	create func() Aggregate
+	rebase ConflictFunc
+}
+
+// Load rehydrates an aggregate from its stream. An AggregateNotFoundError is
+// returned if the stream is empty.
+func (r *Repository) Load(id string) (Aggregate, error) {
+	return r.LoadContext(context.Background(), id)
+}
+
+// LoadContext rehydrates an aggregate from its stream. An
+// AggregateNotFoundError is returned if the stream is empty.
+func (r *Repository) LoadContext(ctx context.Context, id string) (Aggregate, error) {
+	a := r.create()
+	found := false
+	it := IterateContext(ctx, r.store, id, 0)
+	for it.Next() {
+		e, err := r.codec.Decode(it.Record())
+		if err != nil {
+			return nil, err
+		}
+		// the events have already been saved and must not be recorded as changes
+		a.Mutate(e)
+		found = true
+	}
+	// an aggregate must never be rehydrated from a partial history
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, AggregateNotFoundError{Stream: id}
+	}
+	return a, nil
+}
+
+// Save appends the changes of an aggregate to its stream and clears them. The
+// changes are only appended if the stream is still at the version the aggregate
+// has been loaded at, otherwise an OptimisticConcurrencyError is returned.
+func (r *Repository) Save(a Aggregate, muts ...RecordMutation) error {
+	return r.SaveContext(context.Background(), a, muts...)
+}
+
+// SaveContext appends the changes of an aggregate to its stream and clears
+// them. The causation carried by ctx is added to the metadata of the records.
+// An OptimisticConcurrencyError carries the intervening records.
+func (r *Repository) SaveContext(ctx context.Context, a Aggregate, muts ...RecordMutation) error {
+	changes := a.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+	version := a.AggregateVersion()
+	if uint64(len(changes)) > version {
+		return fmt.Errorf("aggregate %s at version %d can not have %d changes", a.AggregateID(), version, len(changes))
+	}
+	// the version the aggregate has been loaded at
+	expected := version - uint64(len(changes))
+	recs, err := r.codec.EncodeAllContext(ctx, changes, muts...)
+	if err != nil {
+		return err
+	}
+	var rebased Aggregate
+	for attempt := 1; ; attempt++ {
+		err := r.append(ctx, a.AggregateID(), expected, recs)
+		oce, ok := err.(OptimisticConcurrencyError)
+		if !ok {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if oce, err = LoadIntervening(ctx, r.store, oce); err != nil {
+			return err
+		}
+		if r.rebase == nil || attempt >= defaultRebaseAttempts {
+			return oce
+		}
+		intervening, err := r.codec.DecodeAll(oce.Intervening)
+		if err != nil {
+			return err
+		}
+		if r.rebase(changes, intervening) {
+			return oce
+		}
+		expected += uint64(len(intervening))
+		if rebased, err = r.rebuild(ctx, a, expected, changes); err != nil {
+			return err
+		}
+	}
+	if rebased != nil {
+		reflect.ValueOf(a).Elem().Set(reflect.ValueOf(rebased).Elem())
+	}
+	a.ClearChanges()
+	return nil
+}
+
+// rebuild rehydrates a new aggregate from the first version events of the
+// stream of a and applies the changes after them.
+func (r *Repository) rebuild(ctx context.Context, a Aggregate, version uint64, changes Events) (Aggregate, error) {
+	b := r.create()
+	if t := reflect.TypeOf(a); t.Kind() != reflect.Ptr || t != reflect.TypeOf(b) {
+		return nil, fmt.Errorf("aggregate %s of type %T can not be rebased", a.AggregateID(), a)
+	}
+	it := IterateContext(ctx, r.store, a.AggregateID(), 0)
+	for it.Next() && it.Record().StreamIndex < version {
+		e, err := r.codec.Decode(it.Record())
+		if err != nil {
+			return nil, err
+		}
+		b.Mutate(e)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	for _, e := range changes {
+		b.Mutate(e)
+	}
+	if v := b.AggregateVersion(); v != version+uint64(len(changes)) {
+		return nil, fmt.Errorf("aggregate %s can not be rebased from version %d", a.AggregateID(), v-uint64(len(changes)))
+	}
+	return b, nil
+}
+
+func (r *Repository) append(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	if cs, ok := r.store.(ContextStore); ok {
+		return cs.AppendContext(ctx, streamID, expectedVersion, records)
+	}
+	return r.store.Append(streamID, expectedVersion, records)
+}
+
+// AggregateNotFoundError is returned when loading an aggregate whose stream is
+// empty.
+type AggregateNotFoundError struct {
+	Stream string
+}
+
+func (e AggregateNotFoundError) Error() string {
+	return fmt.Sprintf("aggregate-not-found-error on stream %s", e.Stream)
+}
+
+func (e AggregateNotFoundError) Code() string {
+	return codeAggregateNotFound
+}
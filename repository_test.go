@@ -0,0 +1,111 @@
+package event
+
+import (
+	"testing"
+)
+
+type repositoryTestAdded struct {
+	ID      string `json:"id"`
+	Counter string `json:"counter"`
+	Amount  int    `json:"amount"`
+}
+
+type repositoryTestCounter struct {
+	id      string
+	version uint64
+	sum     int
+	*ChangeRecorder
+}
+
+func (c *repositoryTestCounter) AggregateID() string {
+	return c.id
+}
+
+func (c *repositoryTestCounter) AggregateVersion() uint64 {
+	return c.version
+}
+
+func (c *repositoryTestCounter) Mutate(e Event) {
+	c.version++
+	switch e := e.(type) {
+	case repositoryTestAdded:
+		c.id = e.Counter
+		c.sum += e.Amount
+	case conflictTestReset:
+		c.id = e.Counter
+		c.sum = 0
+	}
+}
+
+func (c *repositoryTestCounter) add(amount int) {
+	e := repositoryTestAdded{Counter: c.id, Amount: amount}
+	c.Record(e)
+	c.Mutate(e)
+}
+
+func TestRepository(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	codec := NewCodec()
+	codec.Register("added", repositoryTestAdded{})
+	repo := NewRepository(s, codec, func() Aggregate {
+		return &repositoryTestCounter{ChangeRecorder: NewChangeRecorder()}
+	})
+
+	if _, err := repo.Load("counter-1"); err == nil {
+		t.Errorf("expected an error since the aggregate does not exist")
+	} else if _, ok := err.(AggregateNotFoundError); !ok {
+		t.Errorf("expected an AggregateNotFoundError, but got: %v", err)
+	}
+
+	c := &repositoryTestCounter{id: "counter-1", ChangeRecorder: NewChangeRecorder()}
+	c.add(1)
+	c.add(2)
+	if err := repo.Save(c, WithMetadata(map[string]string{"user-name": "admin"})); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if n := len(c.Changes()); n != 0 {
+		t.Errorf("want: %d, got: %d", 0, n)
+	}
+	if v := s.Version("counter-1"); v != 2 {
+		t.Errorf("want: %d, got: %d", 2, v)
+	}
+	if md := string(s.Load("counter-1").Records()[0].Metadata); md != `{"user-name":"admin"}` {
+		t.Errorf("want: %s, got: %s", `{"user-name":"admin"}`, md)
+	}
+
+	// saving without changes is a no-op
+	if err := repo.Save(c); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	a, err := repo.Load("counter-1")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	loaded := a.(*repositoryTestCounter)
+	if loaded.id != "counter-1" || loaded.version != 2 || loaded.sum != 3 || len(loaded.Changes()) != 0 {
+		t.Errorf("unexpected aggregate: %#v", loaded)
+	}
+
+	// a concurrent modification is detected
+	c.add(3)
+	loaded.add(4)
+	if err := repo.Save(c); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	err = repo.Save(loaded)
+	if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an OptimisticConcurrencyError, but got: %v", err)
+	}
+	if n := len(loaded.Changes()); n != 1 {
+		t.Errorf("expected the changes to be kept, but got: %d", n)
+	}
+
+	broken := &repositoryTestCounter{id: "counter-2", ChangeRecorder: NewChangeRecorder()}
+	broken.Record(repositoryTestAdded{Counter: "counter-2", Amount: 1})
+	if err := repo.Save(broken); err == nil {
+		t.Errorf("expected an error since the version does not include the changes")
+	}
+}
@@ -0,0 +1,49 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// StreamMetadataStore is a Store that supports per stream retention. Records
+// that are no longer retained are hidden from LoadSlice, LoadFrom and
+// subscriptions of the stream, but are still part of $all and do not change the
+// version of the stream. They are not removed physically.
+type StreamMetadataStore interface {
+	Store
+	StreamMetadata(streamID string) (StreamMetadata, error)
+	StreamMetadataContext(ctx context.Context, streamID string) (StreamMetadata, error)
+	SetStreamMetadata(streamID string, meta StreamMetadata) error
+	SetStreamMetadataContext(ctx context.Context, streamID string, meta StreamMetadata) error
+}
+
+// StreamMetadata describes the retention of a stream. Zero values disable the
+// respective limit.
+type StreamMetadata struct {
+	MaxCount       uint64        `json:"max-count,omitempty"`       // the number of most recent records that are retained
+	MaxAge         time.Duration `json:"max-age,omitempty"`         // the duration for which records are retained
+	TruncateBefore uint64        `json:"truncate-before,omitempty"` // the index of the first record that is retained
+}
+
+// IsZero reports whether no retention has been configured.
+func (m StreamMetadata) IsZero() bool {
+	return m == StreamMetadata{}
+}
+
+// first returns the index of the first record of a stream with the given version
+// that is retained by max-count and truncate-before.
+func (m StreamMetadata) first(version uint64) uint64 {
+	first := m.TruncateBefore
+	if m.MaxCount > 0 && version > m.MaxCount {
+		first = max(first, version-m.MaxCount)
+	}
+	return first
+}
+
+// cutoff returns the time before which records are no longer retained by
+// max-age. Streams are truncated before the first record that has been
+// recorded at or after the cutoff, so records that have been appended with an
+// older time after it are retained as well.
+func (m StreamMetadata) cutoff(now time.Time) time.Time {
+	return now.Add(-m.MaxAge)
+}
@@ -0,0 +1,155 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreRetention(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(StreamMetadataStore)
+		if !ok {
+			t.Skip("stream metadata is not supported")
+		}
+		exersizeRetention(t, x)
+	})
+}
+
+func exersizeRetention(t *testing.T, s StreamMetadataStore) {
+	now := time.Now().UTC()
+	var recs Records
+	for i := 0; i < 5; i++ {
+		recordedOn := now
+		if i < 2 {
+			recordedOn = now.Add(-2 * time.Hour)
+		}
+		recs = append(recs, Record{Type: "test", RecordedOn: recordedOn, Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	indexes := func(streamID string) []uint64 {
+		var got []uint64
+		for _, r := range s.Load(streamID).Records() {
+			got = append(got, r.StreamIndex)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		meta StreamMetadata
+		exp  []uint64
+	}{
+		{name: "max-count", meta: StreamMetadata{MaxCount: 4}, exp: []uint64{1, 2, 3, 4}},
+		{name: "truncate-before", meta: StreamMetadata{TruncateBefore: 3}, exp: []uint64{3, 4}},
+		{name: "max-age", meta: StreamMetadata{MaxAge: time.Hour}, exp: []uint64{2, 3, 4}},
+		{name: "combined", meta: StreamMetadata{MaxCount: 4, TruncateBefore: 2, MaxAge: time.Hour}, exp: []uint64{2, 3, 4}},
+		{name: "none", meta: StreamMetadata{}, exp: []uint64{0, 1, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := s.SetStreamMetadata("foo", test.meta); err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			meta, err := s.StreamMetadata("foo")
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if meta != test.meta {
+				t.Errorf("want: %#v, got: %#v", test.meta, meta)
+			}
+			if got := indexes("foo"); !reflect.DeepEqual(test.exp, got) {
+				t.Errorf("want: %v, got: %v", test.exp, got)
+			}
+			if bs, ok := s.(BackwardStore); ok {
+				var got []uint64
+				for _, r := range bs.LoadBackward("foo").Records() {
+					got = append([]uint64{r.StreamIndex}, got...)
+				}
+				if !reflect.DeepEqual(test.exp, got) {
+					t.Errorf("want: %v, got: %v", test.exp, got)
+				}
+			}
+			if v := s.Version("foo"); v != 5 {
+				t.Errorf("want: %d, got: %d", 5, v)
+			}
+			if got := indexes(All); len(got) != 5 {
+				t.Errorf("want: %d, got: %d", 5, len(got))
+			}
+		})
+	}
+
+	// slices start at the first record that has not expired.
+	if err := s.SetStreamMetadata("foo", StreamMetadata{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	slice, err := s.LoadSlice("foo", 0, 2)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(slice.Records) != 2 || slice.Records[0].StreamIndex != 2 || slice.Next != 4 || slice.IsEndOfStream {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+
+	// records that have been appended with an older time after the first
+	// retained record are retained as well.
+	recs = Records{
+		{Type: "test", RecordedOn: now.Add(-2 * time.Hour), Data: json.RawMessage(`{}`)},
+		{Type: "test", RecordedOn: now, Data: json.RawMessage(`{}`)},
+		{Type: "test", RecordedOn: now.Add(-3 * time.Hour), Data: json.RawMessage(`{}`)},
+		{Type: "test", RecordedOn: now, Data: json.RawMessage(`{}`)},
+	}
+	if err := s.Append("qux", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := s.SetStreamMetadata("qux", StreamMetadata{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got := indexes("qux"); !reflect.DeepEqual([]uint64{1, 2, 3}, got) {
+		t.Errorf("want: %v, got: %v", []uint64{1, 2, 3}, got)
+	}
+
+	// iterators and subscriptions skip long runs of expired records.
+	recs = nil
+	for i := 0; i < 2*int(defaultIteratorBatchSize); i++ {
+		recs = append(recs, Record{Type: "test", RecordedOn: now.Add(-2 * time.Hour), Data: json.RawMessage(`{}`)})
+	}
+	for i := 0; i < 5; i++ {
+		recs = append(recs, Record{Type: "test", RecordedOn: now, Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("bar", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := s.SetStreamMetadata("bar", StreamMetadata{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	it := Iterate(s, "bar", 0)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("want: %d, got: %d", 5, n)
+	}
+	sub := s.SubscribeToStreamFrom("bar", 0)
+	defer sub.Cancel()
+	select {
+	case r := <-sub.Records():
+		if exp := 2 * defaultIteratorBatchSize; r.StreamIndex != exp {
+			t.Errorf("want: %d, got: %d", exp, r.StreamIndex)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a record")
+	}
+
+	if err := s.SetStreamMetadata(All, StreamMetadata{MaxCount: 1}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,668 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cognicraft/pubsub"
+)
+
+var (
+	_ Store             = (*SegmentStore)(nil)
+	_ ContextStore      = (*SegmentStore)(nil)
+	_ AppendResultStore = (*SegmentStore)(nil)
+	_ ListableStore     = (*SegmentStore)(nil)
+	_ BackwardStore     = (*SegmentStore)(nil)
+)
+
+const (
+	defaultSSBatchSize   = uint64(50)
+	defaultSSSegmentSize = uint64(1000000)
+)
+
+const (
+	segmentIndexFile = "index.log"
+)
+
+func parseSegmentStoreDSN(dsn string) (string, uint64, uint64) {
+	dir := ""
+	batchSize := defaultSSBatchSize
+	segmentSize := defaultSSSegmentSize
+
+	opts := urn(dsn)
+	dir = opts.Path()
+	vals := opts.Query()
+	if opt := vals.Get("batch-size"); opt != "" {
+		if val, err := strconv.ParseUint(opt, 10, 64); err == nil {
+			batchSize = val
+		}
+	}
+	if opt := vals.Get("segment-size"); opt != "" {
+		if val, err := strconv.ParseUint(opt, 10, 64); err == nil && val > 0 {
+			segmentSize = val
+		}
+	}
+	return dir, batchSize, segmentSize
+}
+
+// NewSegmentStore creates a Store that persists records in append-only
+// segment files within a directory. Each segment holds at most segment-size
+// records as JSON lines. The position of every record is kept in a separate
+// append-only index file which is read into memory on startup.
+//
+// The dataSourceName has the form: <directory>[?batch-size=<n>&segment-size=<n>]
+func NewSegmentStore(dataSourceName string) (*SegmentStore, error) {
+	s := &SegmentStore{
+		streams:   map[string][]uint64{},
+		readers:   map[uint64]*os.File{},
+		publisher: pubsub.NewPublisher(),
+	}
+	s.dir, s.batchSize, s.segmentSize = parseSegmentStoreDSN(dataSourceName)
+	return s, s.init()
+}
+
+type SegmentStore struct {
+	dir         string
+	batchSize   uint64
+	segmentSize uint64
+	mu          sync.RWMutex
+	positions   []segmentPosition   // the position of each record in store order
+	streams     map[string][]uint64 // store indexes of the records of each stream
+	index       *os.File
+	writer      *os.File
+	writerID    uint64
+	readersMu   sync.Mutex
+	readers     map[uint64]*os.File
+	publisher   pubsub.Publisher
+}
+
+type segmentPosition struct {
+	StreamID string `json:"stream-id"`
+	Type     string `json:"type,omitempty"`
+	Segment  uint64 `json:"segment"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+}
+
+func (p segmentPosition) end() int64 {
+	return p.Offset + p.Length
+}
+
+func (s *SegmentStore) Version(streamID string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version(streamID)
+}
+
+func (s *SegmentStore) VersionContext(ctx context.Context, streamID string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.Version(streamID), nil
+}
+
+func (s *SegmentStore) Load(streamID string) RecordStream {
+	return s.LoadFrom(streamID, 0)
+}
+
+func (s *SegmentStore) LoadContext(ctx context.Context, streamID string) RecordStream {
+	return s.LoadFromContext(ctx, streamID, 0)
+}
+
+func (s *SegmentStore) LoadFrom(streamID string, skip uint64) RecordStream {
+	return s.LoadFromContext(context.Background(), streamID, skip)
+}
+
+func (s *SegmentStore) LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream {
+	return loadFrom(ctx, s.LoadSliceContext, streamID, skip, s.batchSize)
+}
+
+func (s *SegmentStore) LoadSlice(streamID string, skip uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceContext(context.Background(), streamID, skip, limit)
+}
+
+func (s *SegmentStore) LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSlice(ctx, streamID, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *SegmentStore) loadSlice(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slice := Slice{
+		StreamID: streamID,
+		From:     skip,
+	}
+	version := s.version(streamID)
+	i := skip
+	for ; i < version && uint64(len(slice.Records)) < limit; i++ {
+		if s.skipped(streamID, i) {
+			continue
+		}
+		r, err := s.record(streamID, i)
+		if err != nil {
+			return nil, err
+		}
+		slice.Records = append(slice.Records, r)
+	}
+	slice.IsEndOfStream = i >= version
+	if n := len(slice.Records); n > 0 {
+		slice.Next = slice.Records[n-1].StreamIndex + 1
+	}
+	return &slice, nil
+}
+
+func (s *SegmentStore) LoadBackward(streamID string) RecordStream {
+	return s.LoadBackwardContext(context.Background(), streamID)
+}
+
+func (s *SegmentStore) LoadBackwardContext(ctx context.Context, streamID string) RecordStream {
+	return loadBackward(ctx, s.LoadSliceBackwardContext, streamID, s.batchSize)
+}
+
+func (s *SegmentStore) LoadSliceBackward(streamID string, from uint64, limit uint64) (*Slice, error) {
+	return s.LoadSliceBackwardContext(context.Background(), streamID, from, limit)
+}
+
+func (s *SegmentStore) LoadSliceBackwardContext(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	slice, err := s.loadSliceBackward(ctx, streamID, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice, resolveLinks(ctx, s.loadSlice, slice)
+}
+
+func (s *SegmentStore) loadSliceBackward(ctx context.Context, streamID string, from uint64, limit uint64) (*Slice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	version := s.version(streamID)
+	var recs Records
+	if version > 0 {
+		for i := min(from, version-1); ; i-- {
+			if !s.skipped(streamID, i) {
+				r, err := s.record(streamID, i)
+				if err != nil {
+					return nil, err
+				}
+				recs = append(recs, r)
+			}
+			if uint64(len(recs)) > limit || i == 0 {
+				break
+			}
+		}
+	}
+	return backwardSlice(streamID, from, limit, recs, 0), nil
+}
+
+func (s *SegmentStore) Append(streamID string, expectedVersion uint64, records Records) error {
+	return s.AppendContext(context.Background(), streamID, expectedVersion, records)
+}
+
+func (s *SegmentStore) AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error {
+	_, err := s.AppendWithResult(ctx, streamID, expectedVersion, records)
+	return err
+}
+
+func (s *SegmentStore) AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return AppendResult{}, err
+	}
+	if All == streamID {
+		return s.appendToStore(expectedVersion, records)
+	}
+	if err := checkAppendable(streamID); err != nil {
+		return AppendResult{}, err
+	}
+	s.mu.Lock()
+	streamVersion := s.version(streamID)
+	if err := checkExpectedVersion(streamID, expectedVersion, streamVersion); err != nil {
+		dup, dErr := checkIdempotency(streamID, expectedVersion, streamVersion, 0, records, func(skip uint64, limit uint64) ([]string, error) {
+			var ids []string
+			for i := skip; i < min(streamVersion, skip+limit); i++ {
+				r, err := s.record(streamID, i)
+				if err != nil {
+					return nil, err
+				}
+				ids = append(ids, r.ID)
+			}
+			return ids, nil
+		})
+		s.mu.Unlock()
+		if dup {
+			return AppendResult{StreamID: streamID, Version: streamVersion}, nil
+		}
+		if dErr != nil {
+			return AppendResult{}, dErr
+		}
+		return AppendResult{}, err
+	}
+	res := AppendResult{StreamID: streamID, Version: streamVersion}
+	storeVersion := s.version(All)
+	toWrite := make(Records, len(records))
+	for i, e := range records {
+		if e.RecordedOn.IsZero() {
+			e.RecordedOn = time.Now().UTC()
+		}
+		e.StreamID = streamID
+		e.StreamIndex = streamVersion + uint64(i)
+		e.OriginStreamID = streamID
+		e.OriginStreamIndex = e.StreamIndex
+		toWrite[i] = e
+		res.add(e.StreamIndex, storeVersion+uint64(i), e.RecordedOn)
+	}
+	err := s.write(toWrite)
+	s.mu.Unlock()
+
+	if err != nil {
+		return AppendResult{}, err
+	}
+	s.publisher.Publish(topicAppend, streamID)
+	return res, nil
+}
+
+func (s *SegmentStore) appendToStore(expectedVersion uint64, records Records) (AppendResult, error) {
+	s.mu.Lock()
+	storeVersion := s.version(All)
+	if err := checkExpectedVersion(All, expectedVersion, storeVersion); err != nil {
+		s.mu.Unlock()
+		return AppendResult{}, err
+	}
+	res := AppendResult{StreamID: All, Version: storeVersion}
+	updatedStreams := map[string]bool{}
+	toWrite := make(Records, len(records))
+	for i, e := range records {
+		storeIndex := storeVersion + uint64(i)
+		if e.StreamIndex != storeIndex {
+			s.mu.Unlock()
+			return AppendResult{}, fmt.Errorf("record %d has store index %d but expected %d", i, e.StreamIndex, storeIndex)
+		}
+		e.StreamID = e.OriginStreamID
+		e.StreamIndex = e.OriginStreamIndex
+		toWrite[i] = e
+		updatedStreams[e.StreamID] = true
+		res.add(storeIndex, storeIndex, e.RecordedOn)
+	}
+	err := s.write(toWrite)
+	s.mu.Unlock()
+
+	if err != nil {
+		return AppendResult{}, err
+	}
+	for streamID := range updatedStreams {
+		s.publisher.Publish(topicAppend, streamID)
+	}
+	return res, nil
+}
+
+func (s *SegmentStore) Streams(prefix string, after string, limit int) ([]StreamInfo, error) {
+	return s.StreamsContext(context.Background(), prefix, after, limit)
+}
+
+func (s *SegmentStore) StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id := range s.streams {
+		ids = append(ids, id)
+	}
+	var infos []StreamInfo
+	for _, id := range selectStreamIDs(ids, prefix, after, limit) {
+		version := s.version(id)
+		last, err := s.record(id, version-1)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, StreamInfo{
+			StreamID:  id,
+			Version:   version,
+			LastWrite: last.RecordedOn,
+		})
+	}
+	return infos, nil
+}
+
+func (s *SegmentStore) SubscribeToStream(streamID string) Subscription {
+	return s.SubscribeToStreamContext(context.Background(), streamID)
+}
+
+func (s *SegmentStore) SubscribeToStreamContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, 0)
+}
+
+func (s *SegmentStore) SubscribeToStreamFrom(streamID string, version uint64) Subscription {
+	return s.SubscribeToStreamFromContext(context.Background(), streamID, version)
+}
+
+func (s *SegmentStore) SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription {
+	return &subscription{
+		ctx:       ctx,
+		loadSlice: s.LoadSliceContext,
+		batchSize: s.batchSize,
+		subscribe: s.publisher.Subscribe,
+		streamID:  streamID,
+		from:      version,
+	}
+}
+
+func (s *SegmentStore) SubscribeToStreamFromCurrent(streamID string) Subscription {
+	return s.SubscribeToStreamFromCurrentContext(context.Background(), streamID)
+}
+
+func (s *SegmentStore) SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription {
+	return s.SubscribeToStreamFromContext(ctx, streamID, s.Version(streamID))
+}
+
+func (s *SegmentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, f := range s.readers {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(s.readers, id)
+	}
+	if s.writer != nil {
+		if err := s.writer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		s.writer = nil
+	}
+	if s.index != nil {
+		if err := s.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		s.index = nil
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (s *SegmentStore) init() error {
+	err := os.MkdirAll(s.dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	s.index, err = os.OpenFile(filepath.Join(s.dir, segmentIndexFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// read the index and drop a trailing partial entry that may have been
+	// left behind by an interrupted append.
+	valid := int64(0)
+	in := bufio.NewReader(s.index)
+	for {
+		line, err := in.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var p segmentPosition
+		if err := json.Unmarshal(line, &p); err != nil {
+			return fmt.Errorf("corrupt index entry at offset %d: %v", valid, err)
+		}
+		s.streams[p.StreamID] = append(s.streams[p.StreamID], uint64(len(s.positions)))
+		s.positions = append(s.positions, p)
+		valid += int64(len(line))
+	}
+	if err := s.index.Truncate(valid); err != nil {
+		return err
+	}
+	if _, err := s.index.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	// data written beyond the last indexed record of the active segment has
+	// never been acknowledged and will be discarded.
+	var end int64
+	if n := len(s.positions); n > 0 {
+		last := s.positions[n-1]
+		s.writerID = last.Segment
+		end = last.end()
+	}
+	return s.openWriter(s.writerID, end)
+}
+
+func (s *SegmentStore) openWriter(id uint64, size int64) error {
+	if s.writer != nil {
+		if err := s.writer.Close(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.writer = f
+	s.writerID = id
+	return nil
+}
+
+func (s *SegmentStore) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%010d.seg", id))
+}
+
+// write appends records (with their origin stream fields set) to the
+// segments and the index. It must be called while holding the write lock. A
+// failed write is rolled back, so that neither the segments nor the index keep
+// any of its data.
+func (s *SegmentStore) write(records Records) (err error) {
+	writerOffset, err := s.writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	indexOffset, err := s.index.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	writes := []segmentWrite{{id: s.writerID, size: writerOffset}}
+	defer func() {
+		if err != nil {
+			s.rollback(writes, indexOffset)
+		}
+	}()
+	positions := make([]segmentPosition, 0, len(records))
+	var entries []byte
+	storeIndex := uint64(len(s.positions))
+	for _, r := range records {
+		segment := storeIndex / s.segmentSize
+		if segment != s.writerID {
+			if err := s.writer.Sync(); err != nil {
+				return err
+			}
+			size, exists, err := s.segmentStat(segment)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, segmentWrite{id: segment, size: size, created: !exists})
+			if err := s.openWriter(segment, size); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		offset, err := s.writer.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if _, err := s.writer.Write(data); err != nil {
+			return err
+		}
+		p := segmentPosition{
+			StreamID: r.StreamID,
+			Type:     r.Type,
+			Segment:  segment,
+			Offset:   offset,
+			Length:   int64(len(data)),
+		}
+		entry, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry...)
+		entries = append(entries, '\n')
+		positions = append(positions, p)
+		storeIndex++
+	}
+	if err := s.writer.Sync(); err != nil {
+		return err
+	}
+	// the records only become visible once their index entries are durable.
+	if _, err := s.index.Write(entries); err != nil {
+		return err
+	}
+	if err := s.index.Sync(); err != nil {
+		return err
+	}
+	for _, p := range positions {
+		s.streams[p.StreamID] = append(s.streams[p.StreamID], uint64(len(s.positions)))
+		s.positions = append(s.positions, p)
+	}
+	return nil
+}
+
+// segmentWrite is the state of a segment before a write has added to it.
+type segmentWrite struct {
+	id      uint64
+	size    int64 // the size of the segment before the write
+	created bool  // whether the segment has been created by the write
+}
+
+// rollback discards everything a failed write has added to the segments it has
+// written to and to the index, which has been at indexOffset. Segments that
+// have been created by the write are removed. It must be called while holding
+// the write lock.
+func (s *SegmentStore) rollback(writes []segmentWrite, indexOffset int64) {
+	s.openWriter(writes[0].id, writes[0].size)
+	for _, w := range writes[1:] {
+		if w.created {
+			os.Remove(s.segmentPath(w.id))
+		} else {
+			os.Truncate(s.segmentPath(w.id), w.size)
+		}
+	}
+	if err := s.index.Truncate(indexOffset); err == nil {
+		s.index.Seek(indexOffset, io.SeekStart)
+	}
+}
+
+// segmentStat returns the size of a segment and whether it exists.
+func (s *SegmentStore) segmentStat(id uint64) (int64, bool, error) {
+	info, err := os.Stat(s.segmentPath(id))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return info.Size(), true, nil
+}
+
+// version must be called while holding at least a read lock.
+func (s *SegmentStore) version(streamID string) uint64 {
+	if All == streamID {
+		return uint64(len(s.positions))
+	}
+	if isStoreIndexed(streamID) {
+		for i := len(s.positions) - 1; i >= 0; i-- {
+			if !s.skipped(streamID, uint64(i)) {
+				return uint64(i) + 1
+			}
+		}
+		return 0
+	}
+	return uint64(len(s.streams[streamID]))
+}
+
+// skipped must be called while holding at least a read lock. It reports whether
+// a position of a category or of an event type stream does not hold a record
+// of it.
+func (s *SegmentStore) skipped(streamID string, index uint64) bool {
+	if _, ok := isCategoryStream(streamID); ok {
+		return !inCategoryStream(streamID, s.positions[index].StreamID)
+	}
+	if typ, ok := isEventTypeStream(streamID); ok {
+		return s.positions[index].Type != typ
+	}
+	return false
+}
+
+// record must be called while holding at least a read lock.
+func (s *SegmentStore) record(streamID string, index uint64) (Record, error) {
+	storeIndex := index
+	if !isStoreIndexed(streamID) {
+		storeIndex = s.streams[streamID][index]
+	}
+	p := s.positions[storeIndex]
+	f, err := s.reader(p.Segment)
+	if err != nil {
+		return Record{}, err
+	}
+	data := make([]byte, p.Length)
+	if _, err := f.ReadAt(data, p.Offset); err != nil {
+		return Record{}, err
+	}
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Record{}, err
+	}
+	if isStoreIndexed(streamID) {
+		r.StreamID = streamID
+		r.StreamIndex = storeIndex
+	}
+	return r, nil
+}
+
+// reader must be called while holding at least a read lock.
+func (s *SegmentStore) reader(id uint64) (*os.File, error) {
+	if id == s.writerID {
+		return s.writer, nil
+	}
+	s.readersMu.Lock()
+	defer s.readersMu.Unlock()
+	if f, ok := s.readers[id]; ok {
+		return f, nil
+	}
+	f, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		return nil, err
+	}
+	s.readers[id] = f
+	return f, nil
+}
@@ -0,0 +1,176 @@
+package event
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSegmentStoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dsn := dir + "?segment-size=2"
+
+	s, err := NewSegmentStore(dsn)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	err = s.Append("foo", 0, Records{
+		{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+		{ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+		{ID: "3", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	s.Close()
+
+	// simulate an interrupted append that left a partial index entry behind.
+	f, err := os.OpenFile(filepath.Join(dir, segmentIndexFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	f.Write([]byte(`{"stream-id":"fo`))
+	f.Close()
+
+	s, err = NewSegmentStore(dsn)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	if v := s.Version("foo"); v != 3 {
+		t.Errorf("want: %d, got: %d", 3, v)
+	}
+	err = s.Append("bar", 0, Records{
+		{ID: "4", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	exp := Records{
+		{StreamID: All, StreamIndex: 0, OriginStreamID: "foo", OriginStreamIndex: 0, ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+		{StreamID: All, StreamIndex: 1, OriginStreamID: "foo", OriginStreamIndex: 1, ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+		{StreamID: All, StreamIndex: 2, OriginStreamID: "foo", OriginStreamIndex: 2, ID: "3", Type: "test", Data: json.RawMessage(`{}`)},
+		{StreamID: All, StreamIndex: 3, OriginStreamID: "bar", OriginStreamIndex: 0, ID: "4", Type: "test", Data: json.RawMessage(`{}`)},
+	}
+	if recs := s.Load(All).Records(); !similar(exp, recs) {
+		t.Errorf("want:\n%#v\ngot:\n%#v\n", exp, recs)
+	}
+}
+
+func TestSegmentStoreFailedAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dsn := dir + "?segment-size=2"
+
+	s, err := NewSegmentStore(dsn)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	err = s.Append("foo", 0, Records{
+		{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	// the second record can not be written after the next segment has been
+	// started.
+	records := Records{
+		{ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+		{ID: "3", Type: "test", Data: json.RawMessage(`{`)},
+	}
+	if err := s.Append("foo", 1, records); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if records[0].StreamID != "" {
+		t.Errorf("expected the records of the caller to be unchanged: %#v", records[0])
+	}
+	err = s.Append("foo", 1, Records{
+		{ID: "4", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	s.Close()
+
+	s, err = NewSegmentStore(dsn)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	exp := Records{
+		{StreamID: "foo", StreamIndex: 0, OriginStreamID: "foo", OriginStreamIndex: 0, ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+		{StreamID: "foo", StreamIndex: 1, OriginStreamID: "foo", OriginStreamIndex: 1, ID: "4", Type: "test", Data: json.RawMessage(`{}`)},
+	}
+	if recs := s.Load("foo").Records(); !similar(exp, recs) {
+		t.Errorf("want:\n%#v\ngot:\n%#v\n", exp, recs)
+	}
+}
+
+func TestSegmentStoreFailedAppendAfterResize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewSegmentStore(dir + "?segment-size=1")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	var recs Records
+	for i := 0; i < 3; i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	s.Close()
+
+	// with a larger segment size the next records are appended to segments
+	// that already hold records.
+	s, err = NewSegmentStore(dir + "?segment-size=3")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	sizes := map[uint64]int64{}
+	for id := uint64(0); id < 3; id++ {
+		info, err := os.Stat(s.segmentPath(id))
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		sizes[id] = info.Size()
+	}
+	recs = nil
+	for i := 0; i < 6; i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{`)})
+	if err := s.Append("foo", 3, recs); err == nil {
+		t.Fatalf("expected an error")
+	}
+	for id, size := range sizes {
+		info, err := os.Stat(s.segmentPath(id))
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if info.Size() != size {
+			t.Errorf("segment %d want: %d, got: %d", id, size, info.Size())
+		}
+	}
+	if _, err := os.Stat(s.segmentPath(3)); !os.IsNotExist(err) {
+		t.Errorf("expected segment 3 to be removed, but got: %v", err)
+	}
+	if n := len(s.Load("foo").Records()); n != 3 {
+		t.Errorf("want: %d, got: %d", 3, n)
+	}
+}
@@ -1,8 +1,12 @@
 package event
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/cognicraft/hyper"
 	"github.com/cognicraft/mux"
@@ -53,6 +57,10 @@ func (s *Server) init() {
 
 func (s *Server) onRecord(r Record) {
 	s.signal(fmt.Sprintf("/streams/%s", r.OriginStreamID))
+	if category := Category(r.OriginStreamID); category != "" {
+		s.signal(fmt.Sprintf("/streams/%s", CategoryStream(category)))
+	}
+	s.signal(fmt.Sprintf("/streams/%s", EventTypeStream(r.Type)))
 	s.signal(fmt.Sprintf("/streams/%s", All))
 }
 
@@ -97,6 +105,51 @@ func (s *Server) handleGETStreams(w http.ResponseWriter, r *http.Request) {
 			},
 		},
 	}
+	if ls, ok := s.store.(ListableStore); ok {
+		query := r.URL.Query()
+		prefix := query.Get(nPrefix)
+		after := query.Get(nAfter)
+		limit := defaultPageSize
+		if qLimit := query.Get(nLimit); qLimit != "" {
+			limit, _ = strconv.Atoi(qLimit)
+			if limit < minPageSize {
+				limit = minPageSize
+			}
+		}
+		infos, err := ls.StreamsContext(r.Context(), prefix, after, limit)
+		if err != nil {
+			hyper.Write(w, http.StatusInternalServerError, Response(
+				"could not list streams",
+				err,
+			))
+			return
+		}
+		for _, info := range infos {
+			item := hyper.Item{
+				Type: "stream",
+				Links: hyper.Links{
+					{
+						Rel:  hyper.RelSelf,
+						Href: resolve("./%s", url.PathEscape(info.StreamID)).String(),
+					},
+				},
+			}
+			item.EncodeData(info)
+			res.Items = append(res.Items, item)
+		}
+		if len(infos) == limit {
+			next := resolve("./")
+			next.RawQuery = url.Values{
+				nPrefix: {prefix},
+				nAfter:  {infos[len(infos)-1].StreamID},
+				nLimit:  {strconv.Itoa(limit)},
+			}.Encode()
+			res.Links = append(res.Links, hyper.Link{
+				Rel:  hyper.RelNext,
+				Href: next.String(),
+			})
+		}
+	}
 	hyper.Write(w, http.StatusOK, res)
 }
 
@@ -104,8 +157,15 @@ func (s *Server) handleGETStream(w http.ResponseWriter, r *http.Request) {
 	resolve := hyper.ExternalURLResolver(r)
 	streamID := r.Context().Value(":id").(string)
 	feeder := NewFeeder(s.store, streamID)
-	page := feeder.Page(resolve(""))
-	if streamID != All {
+	page, err := feeder.Page(resolve(""))
+	if err != nil {
+		hyper.Write(w, http.StatusInternalServerError, Response(
+			fmt.Sprintf("could not load %s", streamID),
+			err,
+		))
+		return
+	}
+	if streamID != All && checkAppendable(streamID) == nil {
 		page.AddAction(hyper.Action{
 			Rel:    "append",
 			Href:   resolve("").String(),
@@ -117,6 +177,10 @@ func (s *Server) handleGETStream(w http.ResponseWriter, r *http.Request) {
 					Type:     "application/vnd.events+json",
 					Multiple: true,
 				},
+				{
+					Name: "expected-version",
+					Type: hyper.TypeText,
+				},
 			},
 		})
 	}
@@ -135,6 +199,13 @@ func (s *Server) handlePOSTStream(w http.ResponseWriter, r *http.Request) {
 			))
 			return
 		}
+		if err := checkAppendable(streamID); err != nil {
+			hyper.Write(w, http.StatusBadRequest, Response(
+				fmt.Sprintf("events can not be appended to %s", streamID),
+				err,
+			))
+			return
+		}
 		rs := Records{}
 		err := cmd.Arguments.JSON("events", &rs)
 		if err != nil {
@@ -144,18 +215,43 @@ func (s *Server) handlePOSTStream(w http.ResponseWriter, r *http.Request) {
 			))
 			return
 		}
-		v := s.store.Version(streamID)
-		err = s.store.Append(streamID, v, rs)
+		ev, err := expectedVersion(cmd.Arguments)
 		if err != nil {
 			hyper.Write(w, http.StatusBadRequest, Response(
-				fmt.Sprintf("could not append to %s", streamID),
+				"invalid expected-version",
 				err,
 			))
 			return
 		}
-		hyper.Write(w, http.StatusOK, Response(
-			fmt.Sprintf("appended %d events to %s", len(rs), streamID),
-		))
+		res, err := s.append(r.Context(), streamID, ev, rs)
+		if err != nil {
+			status := http.StatusBadRequest
+			item := Response(
+				fmt.Sprintf("could not append to %s", streamID),
+				err,
+			)
+			switch err := err.(type) {
+			case OptimisticConcurrencyError:
+				status = http.StatusConflict
+				item.EncodeData(err)
+			case IdempotencyError:
+				status = http.StatusConflict
+				item.EncodeData(err)
+			}
+			hyper.Write(w, status, item)
+			return
+		}
+		n := len(rs)
+		if res != nil {
+			n = res.Count()
+		}
+		item := Response(
+			fmt.Sprintf("appended %d events to %s", n, streamID),
+		)
+		if res != nil {
+			item.EncodeData(res)
+		}
+		hyper.Write(w, http.StatusOK, item)
 		return
 	default:
 		hyper.Write(w, http.StatusBadRequest, Response(
@@ -165,6 +261,41 @@ func (s *Server) handlePOSTStream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// append appends records to a stream. The AppendResult is only available if the
+// underlying store is an AppendResultStore.
+func (s *Server) append(ctx context.Context, streamID string, expectedVersion uint64, records Records) (*AppendResult, error) {
+	if rs, ok := s.store.(AppendResultStore); ok {
+		res, err := rs.AppendWithResult(ctx, streamID, expectedVersion, records)
+		if err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
+	return nil, s.store.Append(streamID, expectedVersion, records)
+}
+
+// expectedVersion extracts the optional expected-version argument which may
+// either be a number or one of "any", "no-stream" and "stream-exists". If it is
+// missing or null ExpectAny will be used. Any other value that can not be
+// parsed results in an error.
+func expectedVersion(args hyper.Arguments) (uint64, error) {
+	if _, ok := args["expected-version"]; !ok {
+		return ExpectAny, nil
+	}
+	var raw json.RawMessage
+	if err := args.JSON("expected-version", &raw); err != nil {
+		return 0, err
+	}
+	if string(raw) == "null" {
+		return ExpectAny, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return ParseExpectedVersion(text)
+	}
+	return ParseExpectedVersion(string(raw))
+}
+
 func Response(msg string, errs ...error) hyper.Item {
 	res := hyper.Item{
 		Type: "response",
@@ -0,0 +1,87 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SnapshotStore is a Store that keeps the latest snapshot of the state of each
+// stream, so that the state can be restored without replaying all records of
+// the stream. Older snapshots are replaced, snapshots of deleted streams are
+// removed.
+type SnapshotStore interface {
+	Store
+	// LoadSnapshot returns the latest snapshot of a stream or a zero Snapshot if
+	// no snapshot has been saved.
+	LoadSnapshot(streamID string) (Snapshot, error)
+	LoadSnapshotContext(ctx context.Context, streamID string) (Snapshot, error)
+	// SaveSnapshot saves a snapshot unless a snapshot of a later version has
+	// already been saved.
+	SaveSnapshot(snapshot Snapshot) error
+	SaveSnapshotContext(ctx context.Context, snapshot Snapshot) error
+}
+
+// Snapshot is the serialized state of a stream at a version, i.e. after its
+// first Version records have been applied.
+type Snapshot struct {
+	StreamID   string          `json:"stream-id"`
+	Version    uint64          `json:"version"`
+	RecordedOn time.Time       `json:"recorded-on"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// NewSnapshot creates a snapshot of the state of a stream at a version.
+func NewSnapshot(streamID string, version uint64, state interface{}) (Snapshot, error) {
+	data, err := Encode(state)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{
+		StreamID:   streamID,
+		Version:    version,
+		RecordedOn: time.Now().UTC(),
+		Data:       data,
+	}, nil
+}
+
+// IsZero reports whether the snapshot is missing.
+func (s Snapshot) IsZero() bool {
+	return s.Version == 0 && len(s.Data) == 0
+}
+
+// LoadFromSnapshot loads the latest snapshot of a stream and creates a
+// RecordIterator over the records that have been appended after it:
+//
+//	snapshot, it, err := event.LoadFromSnapshot(ctx, store, streamID)
+//	if err != nil {
+//		...
+//	}
+//	if !snapshot.IsZero() {
+//		event.Decode(snapshot.Data, &state)
+//	}
+//	for it.Next() {
+//		state.Mutate(it.Record())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+func LoadFromSnapshot(ctx context.Context, store SnapshotStore, streamID string) (Snapshot, *RecordIterator, error) {
+	snapshot, err := store.LoadSnapshotContext(ctx, streamID)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	return snapshot, IterateContext(ctx, store, streamID, snapshot.Version), nil
+}
+
+// checkSnapshot validates a snapshot of a stream with the given version.
+func checkSnapshot(snapshot Snapshot, version uint64) error {
+	if All == snapshot.StreamID || checkAppendable(snapshot.StreamID) != nil {
+		return fmt.Errorf("snapshots can not be saved for %s", snapshot.StreamID)
+	}
+	if snapshot.Version > version {
+		return fmt.Errorf("snapshot of %s at version %d is ahead of the stream at version %d", snapshot.StreamID, snapshot.Version, version)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestStoreSnapshot(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(SnapshotStore)
+		if !ok {
+			t.Skip("snapshots are not supported")
+		}
+		exersizeSnapshot(t, x)
+	})
+}
+
+type snapshotTestState struct {
+	Sum int `json:"sum"`
+}
+
+func exersizeSnapshot(t *testing.T, s SnapshotStore) {
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		data, _ := Encode(i)
+		if err := s.Append("counter-1", ExpectAny, Records{{Type: "added", Data: data}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+
+	snapshot, err := s.LoadSnapshot("counter-1")
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if !snapshot.IsZero() {
+		t.Errorf("expected no snapshot, but got: %#v", snapshot)
+	}
+
+	restore := func() (snapshotTestState, Snapshot) {
+		var state snapshotTestState
+		snapshot, it, err := LoadFromSnapshot(ctx, s, "counter-1")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if !snapshot.IsZero() {
+			if err := Decode(snapshot.Data, &state); err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+		}
+		for it.Next() {
+			var n int
+			if err := Decode(it.Record().Data, &n); err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			state.Sum += n
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		return state, snapshot
+	}
+
+	if state, _ := restore(); state.Sum != 15 {
+		t.Errorf("want: %d, got: %d", 15, state.Sum)
+	}
+
+	// the snapshot deliberately differs from the replayed state to prove that
+	// the records it covers are not replayed.
+	snapshot, err = NewSnapshot("counter-1", 3, snapshotTestState{Sum: 100})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := s.SaveSnapshot(snapshot); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	state, loaded := restore()
+	if state.Sum != 109 {
+		t.Errorf("want: %d, got: %d", 109, state.Sum)
+	}
+	if loaded.StreamID != "counter-1" || loaded.Version != 3 || loaded.RecordedOn.IsZero() || string(loaded.Data) != `{"sum":100}` {
+		t.Errorf("unexpected snapshot: %#v", loaded)
+	}
+
+	// older snapshots do not replace newer ones
+	older, _ := NewSnapshot("counter-1", 2, snapshotTestState{Sum: 0})
+	if err := s.SaveSnapshot(older); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if loaded, _ := s.LoadSnapshot("counter-1"); loaded.Version != 3 {
+		t.Errorf("want: %d, got: %d", 3, loaded.Version)
+	}
+
+	ahead, _ := NewSnapshot("counter-1", 6, snapshotTestState{})
+	if err := s.SaveSnapshot(ahead); err == nil {
+		t.Errorf("expected an error since the snapshot is ahead of the stream")
+	}
+	if err := s.SaveSnapshot(Snapshot{StreamID: All, Version: 1, Data: json.RawMessage(`{}`)}); err == nil {
+		t.Errorf("expected an error since %s can not have snapshots", All)
+	}
+
+	if err := s.(DeletableStore).DeleteStream("counter-1", ExpectAny, false); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if loaded, _ := s.LoadSnapshot("counter-1"); !loaded.IsZero() {
+		t.Errorf("expected the snapshot to be deleted, but got: %#v", loaded)
+	}
+}
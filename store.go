@@ -1,8 +1,12 @@
 package event
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"time"
 )
 
 type Store interface {
@@ -17,6 +21,70 @@ type Store interface {
 	SubscribeToStreamFromCurrent(streamID string) Subscription
 }
 
+// ContextStore is a Store whose operations accept a context.Context. Loads and
+// subscriptions stop once the context is done, appends are aborted if the
+// context is done before they are committed.
+type ContextStore interface {
+	Store
+	VersionContext(ctx context.Context, streamID string) (uint64, error)
+	LoadContext(ctx context.Context, streamID string) RecordStream
+	LoadFromContext(ctx context.Context, streamID string, skip uint64) RecordStream
+	LoadSliceContext(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error)
+	AppendContext(ctx context.Context, streamID string, expectedVersion uint64, records Records) error
+	SubscribeToStreamContext(ctx context.Context, streamID string) Subscription
+	SubscribeToStreamFromContext(ctx context.Context, streamID string, version uint64) Subscription
+	SubscribeToStreamFromCurrentContext(ctx context.Context, streamID string) Subscription
+}
+
+// AppendResultStore is a Store that reports the positions that have been
+// assigned to appended records.
+type AppendResultStore interface {
+	Store
+	AppendWithResult(ctx context.Context, streamID string, expectedVersion uint64, records Records) (AppendResult, error)
+}
+
+// DeletableStore is a Store whose streams can be deleted. A soft deleted stream
+// has version 0 and its records are hidden, but they remain part of $all until
+// they are removed by a later scavenge. The stream can be recreated by appending
+// to it again, it continues at the version it has been deleted at. A hard deleted stream is removed and tombstoned and
+// can never be appended to again. The positions of deleted records within $all
+// are not reused.
+type DeletableStore interface {
+	Store
+	DeleteStream(streamID string, expectedVersion uint64, hard bool) error
+	DeleteStreamContext(ctx context.Context, streamID string, expectedVersion uint64, hard bool) error
+}
+
+// AppendResult describes the positions that have been assigned to the records
+// of a single append. The first and last indexes are only meaningful if at
+// least one record has been appended. A retried append whose records are already
+// part of the stream does not append anything.
+type AppendResult struct {
+	StreamID         string      `json:"stream-id"`          // the id of the stream that has been appended to
+	Version          uint64      `json:"version"`            // the version of the stream after the append
+	FirstStreamIndex uint64      `json:"first-stream-index"` // the index of the first record within the stream
+	LastStreamIndex  uint64      `json:"last-stream-index"`  // the index of the last record within the stream
+	FirstStoreIndex  uint64      `json:"first-store-index"`  // the index of the first record within $all
+	LastStoreIndex   uint64      `json:"last-store-index"`   // the index of the last record within $all
+	RecordedOn       []time.Time `json:"recorded-on"`        // the recorded on time of each record
+}
+
+// Count returns the number of records that have been appended.
+func (r AppendResult) Count() int {
+	return len(r.RecordedOn)
+}
+
+func (r *AppendResult) add(streamIndex uint64, storeIndex uint64, recordedOn time.Time) {
+	if len(r.RecordedOn) == 0 {
+		r.FirstStreamIndex = streamIndex
+		r.FirstStoreIndex = storeIndex
+	}
+	r.LastStreamIndex = streamIndex
+	r.LastStoreIndex = storeIndex
+	r.Version = streamIndex + 1
+	r.RecordedOn = append(r.RecordedOn, recordedOn)
+}
+
 type Slice struct {
 	StreamID      string  `json:"stream-id"`
 	From          uint64  `json:"from"`
@@ -25,18 +93,234 @@ type Slice struct {
 	IsEndOfStream bool    `json:"is-end-of-stream"`
 }
 
+// Well-known expected versions that can be used instead of an actual version
+// when appending to a stream.
+const (
+	// ExpectAny appends without an optimistic concurrency check.
+	ExpectAny uint64 = math.MaxUint64
+	// ExpectNoStream requires that the stream does not exist yet.
+	ExpectNoStream uint64 = math.MaxUint64 - 1
+	// ExpectStreamExists requires that the stream already exists.
+	ExpectStreamExists uint64 = math.MaxUint64 - 2
+)
+
+const (
+	expectAny          = "any"
+	expectNoStream     = "no-stream"
+	expectStreamExists = "stream-exists"
+)
+
+// FormatExpectedVersion returns the textual representation of an expected
+// version, i.e. "any", "no-stream", "stream-exists" or the version itself.
+func FormatExpectedVersion(v uint64) string {
+	switch v {
+	case ExpectAny:
+		return expectAny
+	case ExpectNoStream:
+		return expectNoStream
+	case ExpectStreamExists:
+		return expectStreamExists
+	default:
+		return strconv.FormatUint(v, 10)
+	}
+}
+
+// ParseExpectedVersion parses the textual representation of an expected version.
+func ParseExpectedVersion(raw string) (uint64, error) {
+	switch raw {
+	case expectAny:
+		return ExpectAny, nil
+	case expectNoStream:
+		return ExpectNoStream, nil
+	case expectStreamExists:
+		return ExpectStreamExists, nil
+	default:
+		return strconv.ParseUint(raw, 10, 64)
+	}
+}
+
+func checkExpectedVersion(streamID string, expected uint64, actual uint64) error {
+	switch expected {
+	case ExpectAny:
+		return nil
+	case ExpectNoStream:
+		if actual == 0 {
+			return nil
+		}
+	case ExpectStreamExists:
+		if actual > 0 {
+			return nil
+		}
+	default:
+		if actual == expected {
+			return nil
+		}
+	}
+	return OptimisticConcurrencyError{Stream: streamID, Expected: expected, Actual: actual}
+}
+
+// checkExpectedStreamVersion checks the expected version of a stream that might
+// have been soft deleted at version deleted.
+func checkExpectedStreamVersion(streamID string, expected uint64, actual uint64, deleted uint64) error {
+	return checkExpectedVersion(streamID, expected, visibleVersion(actual, deleted))
+}
+
+// visibleVersion returns the version of a stream that might have been soft
+// deleted at version deleted. A soft deleted stream does not exist until it is
+// recreated, although its records continue at the version it has been deleted
+// at.
+func visibleVersion(actual uint64, deleted uint64) uint64 {
+	if actual <= deleted {
+		return 0
+	}
+	return actual
+}
+
+// checkIdempotency decides whether an append that failed the optimistic
+// concurrency check is the retry of an earlier append. This is the case if the
+// stream already contains records with the same IDs at the positions the append
+// expected to write to. Only appends with a concrete expected version or
+// ExpectNoStream are considered, since only those determine the positions. A
+// stream that has been soft deleted at version deleted is recreated at that
+// position. The ids func has to return the IDs of the records of the stream
+// starting at skip.
+//
+// It returns true if all records have already been appended, an
+// IdempotencyError if only a leading part of them has been appended and false
+// otherwise.
+func checkIdempotency(streamID string, expected uint64, actual uint64, deleted uint64, records Records, ids func(skip uint64, limit uint64) ([]string, error)) (bool, error) {
+	if len(records) == 0 {
+		return false, nil
+	}
+	for _, r := range records {
+		if r.ID == "" {
+			return false, nil
+		}
+	}
+	start := expected
+	switch expected {
+	case ExpectAny, ExpectStreamExists:
+		return false, nil
+	case ExpectNoStream:
+		start = 0
+	}
+	if start == 0 {
+		start = deleted
+	}
+	if start >= actual {
+		return false, nil
+	}
+	existing, err := ids(start, uint64(len(records)))
+	if err != nil {
+		return false, err
+	}
+	// only a prefix of the records can have been appended by an earlier
+	// attempt.
+	duplicates := 0
+	for i, id := range existing {
+		if i >= len(records) || records[i].ID != id {
+			break
+		}
+		duplicates++
+	}
+	switch duplicates {
+	case 0:
+		return false, nil
+	case len(records):
+		return true, nil
+	default:
+		return false, IdempotencyError{Stream: streamID, Expected: expected, Duplicates: duplicates, Count: len(records)}
+	}
+}
+
+// StreamDeletedError is returned when appending to or deleting a stream that
+// has been hard deleted.
+type StreamDeletedError struct {
+	Stream string
+}
+
+func (e StreamDeletedError) Error() string {
+	return fmt.Sprintf("stream-deleted-error on stream %s", e.Stream)
+}
+
+func (e StreamDeletedError) Code() string {
+	return codeStreamDeleted
+}
+
+// OptimisticConcurrencyError is returned if a stream is not at the expected
+// version. Stores do not load the Intervening records, LoadIntervening adds them.
 type OptimisticConcurrencyError struct {
-	Stream   string
-	Expected uint64
-	Actual   uint64
+	Stream      string  `json:"stream"`
+	Expected    uint64  `json:"expected"`
+	Actual      uint64  `json:"actual"`
+	Intervening Records `json:"intervening,omitempty"` // the records that have been appended since the expected version
 }
 
 func (e OptimisticConcurrencyError) Error() string {
-	return fmt.Sprintf("optimistic-concurrency-error on stream %s expected version %d but actually got %d", e.Stream, e.Expected, e.Actual)
+	return fmt.Sprintf("optimistic-concurrency-error on stream %s expected version %s but actually got %d", e.Stream, FormatExpectedVersion(e.Expected), e.Actual)
 }
 
+func (e OptimisticConcurrencyError) Code() string {
+	return codeOptimisticConcurrency
+}
+
+// IdempotencyError is returned if only some of the records of an append have
+// already been appended at the expected positions. Appending all of them again
+// is a no-op, appending some of them is rejected.
+type IdempotencyError struct {
+	Stream     string `json:"stream"`
+	Expected   uint64 `json:"expected"`
+	Duplicates int    `json:"duplicates"`
+	Count      int    `json:"count"`
+}
+
+func (e IdempotencyError) Error() string {
+	return fmt.Sprintf("idempotency-error on stream %s expected version %s: %d of %d records have already been appended", e.Stream, FormatExpectedVersion(e.Expected), e.Duplicates, e.Count)
+}
+
+func (e IdempotencyError) Code() string {
+	return codeIdempotency
+}
+
+const (
+	codeOptimisticConcurrency = "optimistic-concurrency-error"
+	codeIdempotency           = "idempotency-error"
+	codeStreamDeleted         = "stream-deleted-error"
+	codeAggregateNotFound     = "aggregate-not-found-error"
+)
+
 type Subscription interface {
 	Records() RecordStream
 	On(callback func(r Record))
 	Cancel() error
 }
+
+// loadFrom streams all records of a stream starting at skip by loading slices
+// of batchSize. The stream is closed once the end of the stream has been
+// reached, a slice could not be loaded or the ctx is done. A load error is not
+// reported, callers who need to see it must use a RecordIterator instead.
+func loadFrom(ctx context.Context, loadSlice loadSliceFunc, streamID string, skip uint64, batchSize uint64) RecordStream {
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		next := skip
+		for {
+			slice, err := loadSlice(ctx, streamID, next, batchSize)
+			if err != nil {
+				return
+			}
+			for _, e := range slice.Records {
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if slice.IsEndOfStream || slice.Next <= next {
+				return
+			}
+			next = slice.Next
+		}
+	}()
+	return out
+}
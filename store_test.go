@@ -1,10 +1,13 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestBasicStore(t *testing.T) {
@@ -14,6 +17,14 @@ func TestBasicStore(t *testing.T) {
 	}
 	defer s.Close()
 	exersizeStore(t, s)
+	exersizeSubscribeFrom(t, s)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	exersizeStore(t, s)
+	exersizeSubscribeFrom(t, s)
 }
 
 func TestChunkedStore(t *testing.T) {
@@ -29,6 +40,158 @@ func TestChunkedStore(t *testing.T) {
 	}
 	defer s.Close()
 	exersizeStore(t, s)
+	exersizeSubscribeFrom(t, s)
+}
+
+func TestSegmentStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewSegmentStore(dir + "?segment-size=2")
+	if err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	defer s.Close()
+	exersizeStore(t, s)
+	exersizeSubscribeFrom(t, s)
+}
+
+func TestExpectedVersion(t *testing.T) {
+	tests := []struct {
+		raw string
+		v   uint64
+	}{
+		{raw: "0", v: 0},
+		{raw: "42", v: 42},
+		{raw: "any", v: ExpectAny},
+		{raw: "no-stream", v: ExpectNoStream},
+		{raw: "stream-exists", v: ExpectStreamExists},
+	}
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			v, err := ParseExpectedVersion(test.raw)
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if v != test.v {
+				t.Errorf("want: %d, got: %d", test.v, v)
+			}
+			if raw := FormatExpectedVersion(v); raw != test.raw {
+				t.Errorf("want: %s, got: %s", test.raw, raw)
+			}
+		})
+	}
+	if _, err := ParseExpectedVersion("some"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestLoadFromContext(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	var recs Records
+	for i := 0; i < 3*int(defaultMSBatchSize); i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("foo", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := s.LoadFromContext(ctx, "foo", 0)
+	<-stream
+	cancel()
+	n := 0
+	for range stream {
+		n++
+	}
+	if n >= len(recs)-1 {
+		t.Errorf("expected the stream to stop early, but got %d records", n)
+	}
+
+	if err := s.AppendContext(ctx, "foo", uint64(len(recs)), recs); err != context.Canceled {
+		t.Errorf("want: %v, got: %v", context.Canceled, err)
+	}
+	if _, err := s.LoadSliceContext(ctx, "foo", 0, 1); err != context.Canceled {
+		t.Errorf("want: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func TestSubscriptionRecordsTwice(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := s.SubscribeToStreamContext(ctx, "foo")
+	first := sub.Records()
+	second := sub.Records()
+	for i := uint64(0); i < 10; i++ {
+		if err := s.Append("foo", i, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		for _, records := range []RecordStream{first, second} {
+			select {
+			case r := <-records:
+				if r.StreamIndex != i {
+					t.Errorf("want: %d, got: %d", i, r.StreamIndex)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected record %d", i)
+			}
+		}
+	}
+	cancel()
+	for range first {
+	}
+	for range second {
+	}
+}
+
+// forEachStore runs fn against a fresh instance of each local backend.
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Run("BasicStore", func(t *testing.T) {
+		s, err := NewBasicStore(":memory:")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		defer s.Close()
+		fn(t, s)
+	})
+	t.Run("MemoryStore", func(t *testing.T) {
+		s := NewMemoryStore()
+		defer s.Close()
+		fn(t, s)
+	})
+	t.Run("ChunkedStore", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "data")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		s, err := NewChunkedStore(dir + "?chunk-size=2")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		defer s.Close()
+		fn(t, s)
+	})
+	t.Run("SegmentStore", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "data")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		s, err := NewSegmentStore(dir + "?segment-size=2")
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		defer s.Close()
+		fn(t, s)
+	})
 }
 
 func exersizeStore(t *testing.T, s Store) {
@@ -127,4 +290,254 @@ func exersizeStore(t *testing.T, s Store) {
 		}
 	}
 
+	// records of $all have to continue at the version of the store.
+	if err := s.Append(All, 6, Records{
+		{StreamID: All, StreamIndex: 8, OriginStreamID: "qux", OriginStreamIndex: 0, ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+	}); err == nil {
+		t.Errorf("expected an error")
+	}
+	if v := s.Version(All); v != 6 {
+		t.Errorf("want: %d, got: %d", 6, v)
+	}
+
+	if err := s.Append("foo", ExpectNoStream, Records{
+		{ID: "5", Type: "test", Data: json.RawMessage(`{}`)},
+	}); err == nil {
+		t.Errorf("expected an error since foo already exists")
+	}
+	if err := s.Append("baz", ExpectStreamExists, Records{
+		{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+	}); err == nil {
+		t.Errorf("expected an error since baz does not exist")
+	}
+	for _, ev := range []uint64{ExpectNoStream, ExpectStreamExists, ExpectAny} {
+		if err := s.Append("baz", ev, Records{
+			{Type: "test", Data: json.RawMessage(`{}`)},
+		}); err != nil {
+			t.Errorf("expected no error for %s, but got: %v", FormatExpectedVersion(ev), err)
+		}
+	}
+	if v := s.Version("baz"); v != 3 {
+		t.Errorf("want: %d, got: %d", 3, v)
+	}
+
+	if rs, ok := s.(AppendResultStore); ok {
+		res, err := rs.AppendWithResult(context.Background(), "qux", ExpectNoStream, Records{
+			{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+			{ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		exp := AppendResult{StreamID: "qux", Version: 2, FirstStreamIndex: 0, LastStreamIndex: 1, FirstStoreIndex: 9, LastStoreIndex: 10}
+		got := res
+		got.RecordedOn = nil
+		if !reflect.DeepEqual(exp, got) {
+			t.Errorf("want:\n%#v\ngot:\n%#v\n", exp, got)
+		}
+		if res.Count() != 2 {
+			t.Errorf("want: %d, got: %d", 2, res.Count())
+		}
+		recs := s.Load("qux").Records()
+		for i, r := range recs {
+			if !r.RecordedOn.Equal(res.RecordedOn[i]) {
+				t.Errorf("want: %v, got: %v", res.RecordedOn[i], r.RecordedOn)
+			}
+		}
+	}
+
+	idem := Records{
+		{ID: "a", Type: "test", Data: json.RawMessage(`{}`)},
+		{ID: "b", Type: "test", Data: json.RawMessage(`{}`)},
+	}
+	if err := s.Append("idem", ExpectNoStream, idem); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	for _, ev := range []uint64{0, ExpectNoStream} {
+		if err := s.Append("idem", ev, idem); err != nil {
+			t.Errorf("expected a retry with %s to be a no-op, but got: %v", FormatExpectedVersion(ev), err)
+		}
+	}
+	if v := s.Version("idem"); v != 2 {
+		t.Errorf("want: %d, got: %d", 2, v)
+	}
+	if err := s.Append("idem", 0, Records{idem[0], {ID: "c", Type: "test", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Errorf("expected an idempotency error")
+	} else if _, ok := err.(IdempotencyError); !ok {
+		t.Errorf("expected an idempotency error, but got: %v", err)
+	}
+	if err := s.Append("idem", 1, Records{idem[1], {ID: "c", Type: "test", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Errorf("expected an idempotency error")
+	} else if _, ok := err.(IdempotencyError); !ok {
+		t.Errorf("expected an idempotency error, but got: %v", err)
+	}
+	if err := s.Append("idem", 0, Records{{ID: "c", Type: "test", Data: json.RawMessage(`{}`)}, idem[1]}); err == nil {
+		t.Errorf("expected an optimistic concurrency error")
+	} else if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an optimistic concurrency error, but got: %v", err)
+	}
+	if err := s.Append("idem", 0, Records{{ID: "c", Type: "test", Data: json.RawMessage(`{}`)}}); err == nil {
+		t.Errorf("expected an optimistic concurrency error")
+	} else if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an optimistic concurrency error, but got: %v", err)
+	}
+	if v := s.Version("idem"); v != 2 {
+		t.Errorf("want: %d, got: %d", 2, v)
+	}
+}
+
+func exersizeSubscribeFrom(t *testing.T, s Store) {
+	var recs Records
+	for i := 0; i < 4; i++ {
+		recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+	}
+	if err := s.Append("sub", 0, recs); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	// a subscription that has caught up with an empty slice continues at its
+	// position instead of starting over.
+	sub := s.SubscribeToStreamFrom("sub", 4)
+	defer sub.Cancel()
+	records := sub.Records()
+	if err := s.Append("sub", 4, Records{{Type: "test", Data: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	select {
+	case r := <-records:
+		if r.StreamIndex != 4 {
+			t.Errorf("want: %d, got: %d", 4, r.StreamIndex)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a record")
+	}
+}
+
+func TestStoreDeleteStream(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(DeletableStore)
+		if !ok {
+			t.Skip("streams can not be deleted")
+		}
+		exersizeDeleteStream(t, x)
+	})
+}
+
+func exersizeDeleteStream(t *testing.T, s DeletableStore) {
+	appendN := func(streamID string, expectedVersion uint64, n int) error {
+		var recs Records
+		for i := 0; i < n; i++ {
+			recs = append(recs, Record{Type: "test", Data: json.RawMessage(`{}`)})
+		}
+		return s.Append(streamID, expectedVersion, recs)
+	}
+	for _, a := range []struct {
+		streamID string
+		n        int
+	}{{"foo", 2}, {"bar", 1}, {"baz", 1}} {
+		if err := appendN(a.streamID, 0, a.n); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+
+	if err := s.DeleteStream("foo", 5, false); err == nil {
+		t.Errorf("expected an optimistic concurrency error")
+	}
+	if err := s.DeleteStream("foo", 2, false); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	if v := s.Version("foo"); v != 0 {
+		t.Errorf("want: %d, got: %d", 0, v)
+	}
+	if recs := s.Load("foo").Records(); len(recs) != 0 {
+		t.Errorf("want: %d, got: %d", 0, len(recs))
+	}
+	// the retention of a stream does not affect whether it has been deleted.
+	if ms, ok := s.(StreamMetadataStore); ok {
+		if err := ms.SetStreamMetadata("foo", StreamMetadata{MaxCount: 10}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if recs := s.Load("foo").Records(); len(recs) != 0 {
+			t.Errorf("want: %d, got: %d", 0, len(recs))
+		}
+		if err := ms.SetStreamMetadata("foo", StreamMetadata{}); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	if v := s.Version(All); v != 4 {
+		t.Errorf("want: %d, got: %d", 4, v)
+	}
+	if ls, ok := s.(ListableStore); ok {
+		infos, err := ls.Streams("", "", 0)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		for _, info := range infos {
+			if info.StreamID == "foo" {
+				t.Errorf("expected foo to be hidden")
+			}
+		}
+	}
+	if err := appendN("foo", ExpectStreamExists, 1); err == nil {
+		t.Errorf("expected an optimistic concurrency error")
+	}
+
+	// a soft deleted stream can be recreated and continues at its version.
+	if err := appendN("foo", ExpectNoStream, 1); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	if recs := s.Load("foo").Records(); len(recs) != 1 || recs[0].StreamIndex != 2 {
+		t.Errorf("unexpected records: %#v", recs)
+	}
+	if v := s.Version("foo"); v != 3 {
+		t.Errorf("want: %d, got: %d", 3, v)
+	}
+
+	// a hard deleted stream can neither be appended to nor be deleted again.
+	if err := s.DeleteStream("baz", ExpectAny, true); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	if err := appendN("baz", ExpectAny, 1); err == nil {
+		t.Errorf("expected a stream deleted error")
+	} else if _, ok := err.(StreamDeletedError); !ok {
+		t.Errorf("expected a stream deleted error, but got: %v", err)
+	}
+	if err := s.DeleteStream("baz", ExpectAny, false); err == nil {
+		t.Errorf("expected a stream deleted error")
+	}
+
+	// positions of deleted records are not reused.
+	if err := appendN("qux", 0, 1); err != nil {
+		t.Errorf("expected no error, but got: %v", err)
+	}
+	recs := s.Load(All).Records()
+	var got []uint64
+	for _, r := range recs {
+		got = append(got, r.StreamIndex)
+	}
+	// soft deleted records remain part of $all.
+	if exp := []uint64{0, 1, 2, 4, 5}; !reflect.DeepEqual(exp, got) {
+		t.Errorf("want: %v, got: %v", exp, got)
+	}
+
+	slice, err := s.LoadSlice(All, 2, 2)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(slice.Records) != 2 || slice.Next != 5 || slice.IsEndOfStream {
+		t.Errorf("unexpected slice: %#v", slice)
+	}
+
+	// links to soft deleted records are not resolved to the records of the
+	// recreated stream.
+	link, err := NewLink(Record{OriginStreamID: "foo", OriginStreamIndex: 0})
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if err := s.Append("links", 0, Records{link}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if recs := s.Load("links").Records(); len(recs) != 1 || recs[0].Type != LinkType {
+		t.Errorf("unexpected records: %#v", recs)
+	}
 }
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cognicraft/hyper"
@@ -26,13 +27,14 @@ func NewStreamer(url string, opts ...StreamerOption) (*Streamer, error) {
 		name:           "",
 		stream:         make(chan Record),
 		done:           make(chan struct{}, 0),
+		ctx:            context.Background(),
 	}
 	for _, opt := range opts {
 		if err := opt(s); err != nil {
 			return nil, err
 		}
 	}
-	s.reqCtx, s.reqCancel = context.WithCancel(context.Background())
+	s.reqCtx, s.reqCancel = context.WithCancel(s.ctx)
 	if s.client == nil {
 		s.client = &http.Client{
 			Transport: &http.Transport{
@@ -56,6 +58,8 @@ type Streamer struct {
 	name             string
 	stream           chan Record
 	done             chan struct{}
+	closeOnce        sync.Once
+	ctx              context.Context
 	reqCtx           context.Context
 	reqCancel        context.CancelFunc
 }
@@ -67,6 +71,14 @@ func UseClient(client *http.Client) func(*Streamer) error {
 	}
 }
 
+// UseContext binds the Streamer to ctx. The stream is closed once the ctx is done.
+func UseContext(ctx context.Context) func(*Streamer) error {
+	return func(s *Streamer) error {
+		s.ctx = ctx
+		return nil
+	}
+}
+
 func Named(name string) func(*Streamer) error {
 	return func(s *Streamer) error {
 		s.name = name
@@ -111,7 +123,9 @@ func (s *Streamer) URL() string {
 }
 
 func (s *Streamer) Close() error {
-	close(s.done)
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
 	s.reqCancel()
 	type connectionCloser interface {
 		CloseIdleConnections()
@@ -137,6 +151,8 @@ func (s *Streamer) run() {
 		select {
 		case <-s.done:
 			return
+		case <-s.reqCtx.Done():
+			return
 		case e := <-frontier:
 			var page hyper.Item
 			var etag string
@@ -144,7 +160,7 @@ func (s *Streamer) run() {
 			if e.etag == "" {
 				page, etag, err = s.getPage(request(e.url))
 				if err != nil {
-					if s.reqCtx.Err() == context.Canceled {
+					if s.reqCtx.Err() != nil {
 						return
 					}
 					time.Sleep(time.Millisecond * 500)
@@ -152,7 +168,7 @@ func (s *Streamer) run() {
 			} else {
 				page, etag, err = s.getPage(longPollRequest(e.url, e.etag, s.timeout))
 				if err != nil {
-					if s.reqCtx.Err() == context.Canceled {
+					if s.reqCtx.Err() != nil {
 						return
 					}
 					time.Sleep(time.Millisecond * 500)
@@ -171,6 +187,8 @@ func (s *Streamer) run() {
 				select {
 				case <-s.done:
 					return
+				case <-s.reqCtx.Done():
+					return
 				case s.stream <- e:
 				}
 			}
@@ -192,6 +210,11 @@ func (s *Streamer) findCurrentVersion() uint64 {
 	if err != nil {
 		return 0
 	}
+	return currentVersion(page)
+}
+
+// currentVersion extracts the version of a stream from its last page.
+func currentVersion(page hyper.Item) uint64 {
 	if len(page.Items) == 0 {
 		return 0
 	}
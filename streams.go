@@ -0,0 +1,42 @@
+package event
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListableStore is a Store that can enumerate its streams. Streams are listed
+// in the order of their IDs. Only streams whose ID starts with prefix and is
+// greater than after are listed, so the last ID of a page can be used as after
+// to request the next page. A limit <= 0 lists all matching streams. $all is
+// never listed.
+type ListableStore interface {
+	Store
+	Streams(prefix string, after string, limit int) ([]StreamInfo, error)
+	StreamsContext(ctx context.Context, prefix string, after string, limit int) ([]StreamInfo, error)
+}
+
+// StreamInfo describes a stream.
+type StreamInfo struct {
+	StreamID  string    `json:"stream-id"`
+	Version   uint64    `json:"version"`
+	LastWrite time.Time `json:"last-write"` // the recorded on time of the last record
+}
+
+// selectStreamIDs returns the sorted page of ids that is selected by prefix,
+// after and limit.
+func selectStreamIDs(ids []string, prefix string, after string, limit int) []string {
+	var selected []string
+	for _, id := range ids {
+		if All != id && strings.HasPrefix(id, prefix) && id > after {
+			selected = append(selected, id)
+		}
+	}
+	sort.Strings(selected)
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
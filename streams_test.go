@@ -0,0 +1,80 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreStreams(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(ListableStore)
+		if !ok {
+			t.Skip("streams can not be listed")
+		}
+		exersizeStreams(t, x)
+	})
+}
+
+func exersizeStreams(t *testing.T, s ListableStore) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"user-1", "user-2", "order-1", "user-1", "user-10"} {
+		err := s.Append(id, ExpectAny, Records{
+			{Type: "test", RecordedOn: start.Add(time.Duration(i) * time.Minute), Data: json.RawMessage(`{}`)},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		after  string
+		limit  int
+		exp    []StreamInfo
+	}{
+		{
+			name:   "prefix",
+			prefix: "user-",
+			exp: []StreamInfo{
+				{StreamID: "user-1", Version: 2, LastWrite: start.Add(3 * time.Minute)},
+				{StreamID: "user-10", Version: 1, LastWrite: start.Add(4 * time.Minute)},
+				{StreamID: "user-2", Version: 1, LastWrite: start.Add(1 * time.Minute)},
+			},
+		},
+		{
+			name:   "page",
+			prefix: "user-",
+			after:  "user-1",
+			limit:  1,
+			exp: []StreamInfo{
+				{StreamID: "user-10", Version: 1, LastWrite: start.Add(4 * time.Minute)},
+			},
+		},
+		{
+			name:  "all",
+			limit: 2,
+			exp: []StreamInfo{
+				{StreamID: "order-1", Version: 1, LastWrite: start.Add(2 * time.Minute)},
+				{StreamID: "user-1", Version: 2, LastWrite: start.Add(3 * time.Minute)},
+			},
+		},
+		{
+			name:   "none",
+			prefix: "invoice-",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := s.Streams(test.prefix, test.after, test.limit)
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if !reflect.DeepEqual(test.exp, got) {
+				t.Errorf("want:\n%#v\ngot:\n%#v\n", test.exp, got)
+			}
+		})
+	}
+}
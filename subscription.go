@@ -1,37 +1,56 @@
 package event
 
 import (
+	"context"
+	"sync"
+
 	"github.com/cognicraft/pubsub"
 )
 
-type loadSliceFunc func(streamID string, skip uint64, limit uint64) (*Slice, error)
+type loadSliceFunc func(ctx context.Context, streamID string, skip uint64, limit uint64) (*Slice, error)
 
 type subscribeFunc func(topic pubsub.Topic, callback func(topic pubsub.Topic, data interface{})) pubsub.Subscription
 
 type subscription struct {
+	ctx       context.Context
 	batchSize uint64
 	subscribe subscribeFunc
 	loadSlice loadSliceFunc
 	streamID  string
 	from      uint64
+	init      sync.Once
+	cancel    sync.Once
 	done      chan struct{}
-	update    chan string
 }
 
 func (s *subscription) Records() RecordStream {
-	s.done = make(chan struct{})
-	s.update = make(chan string)
+	s.init.Do(s.initialize)
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	out := make(chan Record)
+	// every call of Records is notified of appends on its own. The
+	// subscription starts before catching up so that no append is missed and
+	// pending notifications are coalesced, as a single load catches up with all
+	// of them.
+	update := make(chan string, 1)
+	changes := s.subscribe(topicAppend, func(t pubsub.Topic, data interface{}) {
+		s.onAppend(data, update)
+	})
 	go func() {
 		defer close(out)
+		defer changes.Cancel()
 		enqeue := func(streamID string, skip uint64, limit uint64) uint64 {
 			next := skip
 			for {
 				select {
 				case <-s.done:
 					return next
+				case <-ctx.Done():
+					return next
 				default:
-					slice, err := s.loadSlice(streamID, next, limit)
+					slice, err := s.loadSlice(ctx, streamID, next, limit)
 					if err != nil {
 						return next
 					}
@@ -43,26 +62,29 @@ func (s *subscription) Records() RecordStream {
 								return e.StreamIndex + 1
 							}
 							return slice.Next
+						case <-ctx.Done():
+							return e.StreamIndex
 						}
 					}
-					next = slice.Next
-					if slice.IsEndOfStream {
-						return next
+					// slices without records do not necessarily report a
+					// position, a subscription never moves backwards.
+					if slice.IsEndOfStream || slice.Next <= next {
+						return max(next, slice.Next)
 					}
+					next = slice.Next
 				}
 			}
 		}
 		// catch up
 		next := enqeue(s.streamID, s.from, s.batchSize)
 		// follow
-		changes := s.subscribe(topicAppend, s.onAppend)
-		defer changes.Cancel()
 		for {
 			select {
 			case <-s.done:
-				close(s.update)
 				return
-			case <-s.update:
+			case <-ctx.Done():
+				return
+			case <-update:
 				next = enqeue(s.streamID, next, s.batchSize)
 			}
 		}
@@ -80,15 +102,26 @@ func (s *subscription) On(callback func(r Record)) {
 }
 
 func (s *subscription) Cancel() error {
-	if s.done != nil {
+	s.init.Do(s.initialize)
+	s.cancel.Do(func() {
 		close(s.done)
-	}
+	})
 	return nil
 }
 
-func (s *subscription) onAppend(t pubsub.Topic, data interface{}) {
+func (s *subscription) initialize() {
+	s.done = make(chan struct{})
+}
+
+func (s *subscription) onAppend(data interface{}, update chan<- string) {
 	streamID, _ := data.(string)
-	if s.streamID == All || s.streamID == streamID {
-		s.update <- streamID
+	// appends are published by stream, so subscriptions to event type streams
+	// have to check every append for records of their type.
+	_, isEventType := isEventTypeStream(s.streamID)
+	if s.streamID == All || s.streamID == streamID || inCategoryStream(s.streamID, streamID) || isEventType {
+		select {
+		case update <- streamID:
+		default:
+		}
 	}
 }
@@ -0,0 +1,75 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// TimeIndexedStore is a Store that can locate records within $all by the time
+// they have been recorded.
+type TimeIndexedStore interface {
+	Store
+	// IndexAt returns the index within $all of the first record that has been
+	// recorded at or after t. If there is no such record the version of $all is
+	// returned.
+	IndexAt(t time.Time) (uint64, error)
+	IndexAtContext(ctx context.Context, t time.Time) (uint64, error)
+}
+
+// LoadFromTime loads the records of $all starting with the first record that
+// has been recorded at or after t.
+func LoadFromTime(store TimeIndexedStore, t time.Time) (RecordStream, error) {
+	index, err := store.IndexAt(t)
+	if err != nil {
+		return nil, err
+	}
+	return store.LoadFrom(All, index), nil
+}
+
+// LoadTimeRange loads the records of $all that lie between the first record
+// that has been recorded at or after from and the first record that has been
+// recorded at or after to (exclusive).
+func LoadTimeRange(store TimeIndexedStore, from time.Time, to time.Time) (RecordStream, error) {
+	return LoadTimeRangeContext(context.Background(), store, from, to)
+}
+
+// LoadTimeRangeContext loads the records of $all that lie between the first
+// record that has been recorded at or after from and the first record that has
+// been recorded at or after to (exclusive). Loading stops once the ctx is done.
+func LoadTimeRangeContext(ctx context.Context, store TimeIndexedStore, from time.Time, to time.Time) (RecordStream, error) {
+	start, err := store.IndexAtContext(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := store.IndexAtContext(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		it := IterateContext(ctx, store, All, start)
+		for it.Next() {
+			r := it.Record()
+			if r.StreamIndex >= end {
+				return
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// SubscribeToStoreFromTime subscribes to $all starting with the first record
+// that has been recorded at or after t.
+func SubscribeToStoreFromTime(store TimeIndexedStore, t time.Time) (Subscription, error) {
+	index, err := store.IndexAt(t)
+	if err != nil {
+		return nil, err
+	}
+	return store.SubscribeToStreamFrom(All, index), nil
+}
@@ -0,0 +1,88 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreTimeRange(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(TimeIndexedStore)
+		if !ok {
+			t.Skip("records can not be read by time")
+		}
+		exersizeTimeRange(t, x)
+	})
+}
+
+func exersizeTimeRange(t *testing.T, s TimeIndexedStore) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time {
+		return start.Add(time.Duration(i) * time.Minute)
+	}
+	for i, id := range []string{"foo", "bar", "foo", "bar", "foo"} {
+		err := s.Append(id, ExpectAny, Records{
+			{Type: "test", RecordedOn: at(i), Data: json.RawMessage(`{}`)},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	}
+	indexes := func(rs RecordStream) []uint64 {
+		var got []uint64
+		for _, r := range rs.Records() {
+			got = append(got, r.StreamIndex)
+		}
+		return got
+	}
+
+	for _, test := range []struct {
+		t   time.Time
+		exp uint64
+	}{
+		{t: at(-1), exp: 0},
+		{t: at(2), exp: 2},
+		{t: at(2).Add(30 * time.Second), exp: 3},
+		{t: at(5), exp: 5},
+	} {
+		got, err := s.IndexAt(test.t)
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if got != test.exp {
+			t.Errorf("%v want: %d, got: %d", test.t, test.exp, got)
+		}
+	}
+
+	rs, err := LoadFromTime(s, at(3))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got := indexes(rs); !reflect.DeepEqual([]uint64{3, 4}, got) {
+		t.Errorf("want: %v, got: %v", []uint64{3, 4}, got)
+	}
+
+	rs, err = LoadTimeRange(s, at(1), at(3))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if got := indexes(rs); !reflect.DeepEqual([]uint64{1, 2}, got) {
+		t.Errorf("want: %v, got: %v", []uint64{1, 2}, got)
+	}
+
+	sub, err := SubscribeToStoreFromTime(s, at(4))
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	defer sub.Cancel()
+	select {
+	case r := <-sub.Records():
+		if r.StreamIndex != 4 {
+			t.Errorf("want: %d, got: %d", 4, r.StreamIndex)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a record")
+	}
+}
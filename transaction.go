@@ -0,0 +1,76 @@
+package event
+
+import (
+	"context"
+	"fmt"
+)
+
+// TransactionalStore is a Store that can append to several streams within one
+// atomic commit. Either all appends succeed or none of them is applied.
+// Subscribers are only notified once the commit succeeded.
+type TransactionalStore interface {
+	Store
+	AppendMulti(ctx context.Context, appends ...StreamAppend) ([]AppendResult, error)
+}
+
+// StreamAppend describes the append of records to a single stream as part of a
+// multi-stream append.
+type StreamAppend struct {
+	StreamID        string
+	ExpectedVersion uint64
+	Records         Records
+}
+
+// NewTransaction creates a Transaction that is committed to store.
+func NewTransaction(store TransactionalStore) *Transaction {
+	return &Transaction{
+		store: store,
+	}
+}
+
+// Transaction collects appends to several streams and commits them atomically:
+//
+//	tx := event.NewTransaction(store)
+//	tx.Append("order-1", 3, orderRecords)
+//	tx.Append("stock-7", 12, stockRecords)
+//	results, err := tx.Commit(ctx)
+type Transaction struct {
+	store   TransactionalStore
+	appends []StreamAppend
+}
+
+// Append adds the append of records to a stream to the transaction. Nothing is
+// written before the transaction is committed.
+func (t *Transaction) Append(streamID string, expectedVersion uint64, records Records) {
+	t.appends = append(t.appends, StreamAppend{
+		StreamID:        streamID,
+		ExpectedVersion: expectedVersion,
+		Records:         records,
+	})
+}
+
+// Commit appends all records atomically. The results are in the order in which
+// the appends have been added.
+func (t *Transaction) Commit(ctx context.Context) ([]AppendResult, error) {
+	return t.store.AppendMulti(ctx, t.appends...)
+}
+
+// checkStreamAppends validates the appends of a multi-stream append. Each stream
+// may only be appended to once and records can neither be appended to $all nor
+// to category streams.
+func checkStreamAppends(appends []StreamAppend) error {
+	seen := map[string]bool{}
+	for _, a := range appends {
+		if All == a.StreamID {
+			return fmt.Errorf("records can not be appended to %s within a transaction", All)
+		}
+		if err := checkAppendable(a.StreamID); err != nil {
+			return err
+		}
+		if seen[a.StreamID] {
+			return fmt.Errorf("stream %s is appended to more than once within a transaction", a.StreamID)
+		}
+		seen[a.StreamID] = true
+	}
+	return nil
+}
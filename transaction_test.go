@@ -0,0 +1,74 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestStoreTransaction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		x, ok := s.(TransactionalStore)
+		if !ok {
+			t.Skip("transactions are not supported")
+		}
+		exersizeTransaction(t, x)
+	})
+}
+
+func exersizeTransaction(t *testing.T, s TransactionalStore) {
+	ctx := context.Background()
+	if err := s.Append("foo", 0, Records{
+		{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+	}); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	tx := NewTransaction(s)
+	tx.Append("foo", 1, Records{
+		{ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	tx.Append("bar", ExpectNoStream, Records{
+		{ID: "1", Type: "test", Data: json.RawMessage(`{}`)},
+		{ID: "2", Type: "test", Data: json.RawMessage(`{}`)},
+	})
+	results, err := tx.Commit(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want: %d, got: %d", 2, len(results))
+	}
+	if results[0].StreamID != "foo" || results[0].FirstStoreIndex != 1 || results[0].Version != 2 {
+		t.Errorf("unexpected result: %#v", results[0])
+	}
+	if results[1].StreamID != "bar" || results[1].FirstStoreIndex != 2 || results[1].LastStoreIndex != 3 || results[1].Version != 2 {
+		t.Errorf("unexpected result: %#v", results[1])
+	}
+
+	// the failing append to bar must not leave the append to foo behind.
+	_, err = s.AppendMulti(ctx,
+		StreamAppend{StreamID: "foo", ExpectedVersion: 2, Records: Records{{ID: "3", Type: "test", Data: json.RawMessage(`{}`)}}},
+		StreamAppend{StreamID: "bar", ExpectedVersion: 0, Records: Records{{ID: "3", Type: "test", Data: json.RawMessage(`{}`)}}},
+	)
+	if _, ok := err.(OptimisticConcurrencyError); !ok {
+		t.Errorf("expected an optimistic concurrency error, but got: %v", err)
+	}
+	if v := s.Version("foo"); v != 2 {
+		t.Errorf("want: %d, got: %d", 2, v)
+	}
+	if v := s.Version(All); v != 4 {
+		t.Errorf("want: %d, got: %d", 4, v)
+	}
+
+	_, err = s.AppendMulti(ctx,
+		StreamAppend{StreamID: "foo", ExpectedVersion: ExpectAny},
+		StreamAppend{StreamID: "foo", ExpectedVersion: ExpectAny},
+	)
+	if err == nil {
+		t.Errorf("expected an error since foo is appended to twice")
+	}
+	if _, err := s.AppendMulti(ctx, StreamAppend{StreamID: All, ExpectedVersion: ExpectAny}); err == nil {
+		t.Errorf("expected an error since records can not be appended to %s", All)
+	}
+}
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"reflect"
@@ -20,6 +21,26 @@ func parseTime(raw string) time.Time {
 	return t
 }
 
+// transact runs fn within a transaction that is bound to ctx. The transaction
+// is committed if fn succeeds and rolled back otherwise.
+func transact(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 func min(a, b uint64) uint64 {
 	if a < b {
 		return a